@@ -0,0 +1,281 @@
+// Package analysis mines stored classifications and feedback for terms that
+// would make good keywords and phrases.
+package analysis
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"cfs/proc"
+)
+
+const Unknown = "Unknown"
+
+// Document is a text together with the category it belongs to, or Unknown.
+type Document struct {
+	Text     string
+	Category string
+}
+
+// Documents labels every classified item with its latest feedback, if any,
+// and otherwise with the category the classifier gave it.
+func Documents(results []proc.ClassificationResult, feedback []proc.Feedback) []Document {
+	corrected := make(map[string]string)
+	for _, f := range feedback {
+		corrected[f.Item] = f.Category
+	}
+
+	documents := make([]Document, 0, len(results))
+	for _, result := range results {
+		category := result.Category
+		if c, ok := corrected[result.Item]; ok {
+			category = c
+		}
+		documents = append(documents, Document{Text: result.Item, Category: category})
+	}
+	return documents
+}
+
+type Options struct {
+	// Limit caps each list of suggestions. Zero means 20.
+	Limit int
+	// MinCount is the number of documents a term must appear in to be
+	// suggested. Zero means 2.
+	MinCount int
+	// Existing categories; terms they already use are not suggested again.
+	Existing []proc.Category
+}
+
+type Suggestion struct {
+	Term  string  `json:"term"`
+	Score float64 `json:"score"`
+	Count int     `json:"count"`
+}
+
+type CategorySuggestions struct {
+	Category string       `json:"category"`
+	Keywords []Suggestion `json:"keywords"`
+	Phrases  []Suggestion `json:"phrases"`
+}
+
+type Report struct {
+	Categories []CategorySuggestions `json:"categories"`
+	Unknown    CategorySuggestions   `json:"unknown"`
+}
+
+// Suggest ranks candidate keywords and phrases for every labeled category and
+// for the Unknown bucket.
+//
+// Keywords and phrases of a category are scored by their log-odds ratio
+// against all other documents, as a z-score with a small uniform prior
+// (Monroe et al., "Fightin' Words"), so terms frequent everywhere score low.
+// Only terms more than one standard deviation above chance are kept. Phrases
+// are pairs of adjacent words that also have a positive pointwise mutual
+// information, so they occur together more than chance. Unknown documents
+// have no category to contrast, so their terms are scored by document
+// frequency times inverse document frequency over the whole corpus, to
+// surface what no rule covers.
+func Suggest(documents []Document, opts Options) Report {
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.MinCount <= 0 {
+		opts.MinCount = 2
+	}
+
+	c := newCorpus(documents)
+	existing, used := existingTerms(opts.Existing)
+
+	report := Report{Categories: make([]CategorySuggestions, 0)}
+	for _, category := range c.categories {
+		if category == Unknown {
+			continue
+		}
+		report.Categories = append(report.Categories, CategorySuggestions{
+			Category: category,
+			Keywords: c.discriminative(c.words, category, existing[category], opts),
+			Phrases:  c.discriminative(c.bigrams, category, existing[category], opts),
+		})
+	}
+	report.Unknown = CategorySuggestions{
+		Category: Unknown,
+		Keywords: c.uncovered(c.words, used, opts),
+		Phrases:  c.uncovered(c.bigrams, used, opts),
+	}
+	return report
+}
+
+// termStats counts, for one normalized term, the documents of each category
+// it appears in and its most frequent surface form.
+type termStats struct {
+	documents map[string]int
+	total     int
+	surfaces  map[string]int
+}
+
+func (s *termStats) surface() string {
+	best, count := "", 0
+	for surface, n := range s.surfaces {
+		if n > count || (n == count && surface < best) {
+			best, count = surface, n
+		}
+	}
+	return best
+}
+
+type corpus struct {
+	pipeline   proc.Pipeline
+	size       int
+	categories []string
+	documents  map[string]int
+	words      map[string]*termStats
+	bigrams    map[string]*termStats
+}
+
+func newCorpus(documents []Document) *corpus {
+	c := &corpus{
+		pipeline:  proc.DefaultPipeline(),
+		size:      len(documents),
+		documents: make(map[string]int),
+		words:     make(map[string]*termStats),
+		bigrams:   make(map[string]*termStats),
+	}
+
+	for _, document := range documents {
+		c.documents[document.Category]++
+		tokens := c.pipeline.Tokenize(document.Text)
+		seen := make(map[string]bool)
+		for i, token := range tokens {
+			if token.Stop {
+				continue
+			}
+			c.add(c.words, seen, token.Text, document.Text[token.Start:token.End], document.Category)
+			if i+1 < len(tokens) && !tokens[i+1].Stop {
+				next := tokens[i+1]
+				c.add(c.bigrams, seen, token.Text+" "+next.Text, document.Text[token.Start:next.End], document.Category)
+			}
+		}
+	}
+
+	for category := range c.documents {
+		c.categories = append(c.categories, category)
+	}
+	sort.Strings(c.categories)
+	return c
+}
+
+// add counts a term once per document.
+func (c *corpus) add(terms map[string]*termStats, seen map[string]bool, key, surface, category string) {
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+	s, ok := terms[key]
+	if !ok {
+		s = &termStats{documents: make(map[string]int), surfaces: make(map[string]int)}
+		terms[key] = s
+	}
+	s.documents[category]++
+	s.total++
+	s.surfaces[strings.ToLower(surface)]++
+}
+
+const (
+	logOddsPrior = 0.5
+	minZScore    = 1.0
+)
+
+func (c *corpus) discriminative(terms map[string]*termStats, category string, skip map[string]bool, opts Options) []Suggestion {
+	inCategory, inRest := 0.0, 0.0
+	for _, s := range terms {
+		inCategory += float64(s.documents[category])
+		inRest += float64(s.total - s.documents[category])
+	}
+	prior := logOddsPrior * float64(len(terms))
+
+	suggestions := make([]Suggestion, 0)
+	for key, s := range terms {
+		count := s.documents[category]
+		if count < opts.MinCount || skip[key] {
+			continue
+		}
+		if strings.Contains(key, " ") && c.pmi(key, s) <= 0 {
+			continue
+		}
+		y, rest := float64(count), float64(s.total-count)
+		delta := math.Log((y+logOddsPrior)/(inCategory+prior-y-logOddsPrior)) -
+			math.Log((rest+logOddsPrior)/(inRest+prior-rest-logOddsPrior))
+		z := delta / math.Sqrt(1/(y+logOddsPrior)+1/(rest+logOddsPrior))
+		if z > minZScore {
+			suggestions = append(suggestions, Suggestion{Term: s.surface(), Score: z, Count: count})
+		}
+	}
+	return top(suggestions, opts.Limit)
+}
+
+// pmi is the pointwise mutual information of the two words of a bigram,
+// measured over documents.
+func (c *corpus) pmi(key string, s *termStats) float64 {
+	first, second, _ := strings.Cut(key, " ")
+	a, b := c.words[first], c.words[second]
+	if a == nil || b == nil {
+		return 0
+	}
+	return math.Log(float64(s.total) * float64(c.size) / (float64(a.total) * float64(b.total)))
+}
+
+func (c *corpus) uncovered(terms map[string]*termStats, skip map[string]bool, opts Options) []Suggestion {
+	suggestions := make([]Suggestion, 0)
+	for key, s := range terms {
+		count := s.documents[Unknown]
+		if count < opts.MinCount || skip[key] {
+			continue
+		}
+		if strings.Contains(key, " ") && c.pmi(key, s) <= 0 {
+			continue
+		}
+		score := float64(count) * math.Log(1+float64(c.size)/float64(s.total))
+		suggestions = append(suggestions, Suggestion{Term: s.surface(), Score: score, Count: count})
+	}
+	return top(suggestions, opts.Limit)
+}
+
+func top(suggestions []Suggestion, limit int) []Suggestion {
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Term < suggestions[j].Term
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// existingTerms normalizes the keywords and phrases of every category, per
+// category and all together.
+func existingTerms(categories []proc.Category) (map[string]map[string]bool, map[string]bool) {
+	pipeline := proc.DefaultPipeline()
+	byCategory := make(map[string]map[string]bool)
+	all := make(map[string]bool)
+	for _, category := range categories {
+		terms := make(map[string]bool)
+		for _, list := range [][]proc.Term{category.Keywords, category.Phrases} {
+			for _, term := range list {
+				words := make([]string, 0)
+				for _, token := range pipeline.Tokenize(term.Text) {
+					if !token.Stop {
+						words = append(words, token.Text)
+					}
+				}
+				key := strings.Join(words, " ")
+				terms[key] = true
+				all[key] = true
+			}
+		}
+		byCategory[category.Name] = terms
+	}
+	return byCategory, all
+}
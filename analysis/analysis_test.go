@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"testing"
+
+	"cfs/proc"
+)
+
+func TestSuggest(t *testing.T) {
+	documents := []Document{
+		{Text: "Our kubernetes cluster needs more nodes", Category: "Technology"},
+		{Text: "Scaling the kubernetes cluster for the launch", Category: "Technology"},
+		{Text: "The kubernetes cluster upgrade failed", Category: "Technology"},
+		{Text: "Deploying the new release today", Category: "Technology"},
+		{Text: "Slow cooked beef stew for the weekend", Category: "Food"},
+		{Text: "A hearty beef stew with root vegetables", Category: "Food"},
+		{Text: "Beef stew is perfect for winter", Category: "Food"},
+		{Text: "Preparing the new release of the menu", Category: "Food"},
+		{Text: "Quarterly invoice reconciliation is late", Category: Unknown},
+		{Text: "Invoice reconciliation for the new vendor", Category: Unknown},
+	}
+
+	report := Suggest(documents, Options{
+		Existing: []proc.Category{{Name: "Food", Keywords: proc.Terms("stew")}},
+	})
+
+	if len(report.Categories) != 2 {
+		t.Fatalf("Suggest() categories = %+v, want Food and Technology", report.Categories)
+	}
+	food, technology := report.Categories[0], report.Categories[1]
+
+	if !contains(technology.Keywords, "kubernetes") || !contains(technology.Phrases, "kubernetes cluster") {
+		t.Errorf("Technology suggestions = %+v", technology)
+	}
+	if contains(technology.Keywords, "new") || contains(technology.Keywords, "release") {
+		t.Errorf("Technology keywords = %+v, want no terms shared with Food", technology.Keywords)
+	}
+	if !contains(food.Keywords, "beef") || contains(food.Keywords, "stew") {
+		t.Errorf("Food keywords = %+v, want beef but not the existing stew", food.Keywords)
+	}
+	if !contains(report.Unknown.Keywords, "invoice") || !contains(report.Unknown.Phrases, "invoice reconciliation") {
+		t.Errorf("Unknown suggestions = %+v", report.Unknown)
+	}
+
+	if limited := Suggest(documents, Options{Limit: 1}); len(limited.Categories[1].Keywords) != 1 {
+		t.Errorf("Suggest() with limit 1 keywords = %+v", limited.Categories[1].Keywords)
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	results := []proc.ClassificationResult{
+		{Item: "a", Category: "Technology"},
+		{Item: "b", Category: Unknown},
+	}
+	feedback := []proc.Feedback{
+		{Item: "b", Category: "Food"},
+		{Item: "b", Category: "Science"},
+	}
+
+	documents := Documents(results, feedback)
+	if documents[0].Category != "Technology" || documents[1].Category != "Science" {
+		t.Errorf("Documents() = %+v, want the latest feedback to win", documents)
+	}
+}
+
+func contains(suggestions []Suggestion, term string) bool {
+	for _, s := range suggestions {
+		if s.Term == term {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 
 	_ "github.com/mattn/go-sqlite3"
 
@@ -34,8 +35,69 @@ func (d *Database) Init() error {
 			contexts TEXT NOT NULL,
 			excluders TEXT NOT NULL
 		);
+		CREATE TABLE IF NOT EXISTS feedback (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			item TEXT NOT NULL,
+			category TEXT NOT NULL,
+			predicted TEXT NOT NULL,
+			reviewer TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS examples (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			text TEXT NOT NULL,
+			category TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS lexicons (
+			name TEXT PRIMARY KEY,
+			terms TEXT NOT NULL
+		);
 	`)
-	return err
+	if err != nil {
+		return err
+	}
+	return d.migrate()
+}
+
+var columns = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"categories", "languages", "TEXT NOT NULL DEFAULT '[]'"},
+	{"categories", "weights", "TEXT NOT NULL DEFAULT 'null'"},
+	{"categories", "fuzzy", "INTEGER NOT NULL DEFAULT 0"},
+	{"categories", "patterns", "TEXT NOT NULL DEFAULT '[]'"},
+	{"categories", "expression", "TEXT NOT NULL DEFAULT ''"},
+	{"categories", "parent", "TEXT NOT NULL DEFAULT ''"},
+	{"classifications", "path", "TEXT NOT NULL DEFAULT 'null'"},
+	{"classifications", "score", "REAL NOT NULL DEFAULT 0"},
+	{"categories", "min_confidence", "REAL NOT NULL DEFAULT 0"},
+	{"categories", "min_matches", "INTEGER NOT NULL DEFAULT 0"},
+	{"classifications", "abstained", "INTEGER NOT NULL DEFAULT 0"},
+	{"classifications", "abstain_reason", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrate adds columns introduced after a database was first created.
+func (d *Database) migrate() error {
+	for _, c := range columns {
+		var count int
+		err := d.db.QueryRow(
+			"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+			c.table, c.column,
+		).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		_, err = d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (d *Database) Close() error {
@@ -49,43 +111,43 @@ func (d *Database) Seed() {
 	var categories = []proc.Category{
 		{
 			Name: "Technology",
-			Keywords: []string{
+			Keywords: proc.Terms(
 				"computer", "software", "program", "code", "algorithm",
 				"database", "network", "server", "application", "system",
-			},
-			Phrases: []string{
+			),
+			Phrases: proc.Terms(
 				"artificial intelligence",
 				"machine learning",
 				"deep learning",
 				"neural network",
 				"cloud computing",
+			),
+			Contexts: map[string]proc.Context{
+				"development": {Related: []string{"software", "web", "app", "mobile"}},
+				"data":        {Related: []string{"processing", "analysis", "storage"}},
+				"security":    {Related: []string{"cyber", "network", "encryption"}},
 			},
-			Contexts: map[string][]string{
-				"development": {"software", "web", "app", "mobile"},
-				"data":        {"processing", "analysis", "storage"},
-				"security":    {"cyber", "network", "encryption"},
-			},
-			Excluders: []string{"recipe", "cook", "bake", "ingredient"},
+			Excluders: proc.Terms("recipe", "cook", "bake", "ingredient"),
 		},
 		{
 			Name: "Food and Cooking",
-			Keywords: []string{
+			Keywords: proc.Terms(
 				"cook", "recipe", "food", "meal", "ingredient",
 				"kitchen", "dish", "taste", "flavor", "cuisine",
-			},
-			Phrases: []string{
+			),
+			Phrases: proc.Terms(
 				"healthy eating",
 				"meal prep",
 				"cooking instructions",
 				"recipe guide",
 				"food preparation",
+			),
+			Contexts: map[string]proc.Context{
+				"preparation": {Related: []string{"cook", "bake", "grill", "roast"}},
+				"ingredients": {Related: []string{"fresh", "organic", "raw", "dried"}},
+				"taste":       {Related: []string{"delicious", "savory", "sweet", "spicy"}},
 			},
-			Contexts: map[string][]string{
-				"preparation": {"cook", "bake", "grill", "roast"},
-				"ingredients": {"fresh", "organic", "raw", "dried"},
-				"taste":       {"delicious", "savory", "sweet", "spicy"},
-			},
-			Excluders: []string{"computer", "program", "code", "algorithm"},
+			Excluders: proc.Terms("computer", "program", "code", "algorithm"),
 		},
 	}
 
@@ -96,21 +158,28 @@ func (d *Database) Seed() {
 	}
 }
 
+const classificationColumns = "item, category, confidence, matches, path, score, abstained, abstain_reason"
+
 func (d *Database) AddClassification(item string, result proc.ClassificationResult) error {
 	matchesJSON, err := json.Marshal(result.Matches)
 	if err != nil {
 		return err
 	}
+	pathJSON, err := json.Marshal(result.Path)
+	if err != nil {
+		return err
+	}
 
 	_, err = d.db.Exec(
-		"INSERT OR REPLACE INTO classifications (item, category, confidence, matches) VALUES (?, ?, ?, ?)",
-		item, result.Category, result.Confidence, string(matchesJSON),
+		"INSERT OR REPLACE INTO classifications ("+classificationColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		item, result.Category, result.Confidence, string(matchesJSON), string(pathJSON), result.Score,
+		result.Abstained, result.AbstainReason,
 	)
 	return err
 }
 
 func (d *Database) GetClassifications() ([]proc.ClassificationResult, error) {
-	rows, err := d.db.Query("SELECT item, category, confidence, matches FROM classifications")
+	rows, err := d.db.Query("SELECT " + classificationColumns + " FROM classifications")
 	if err != nil {
 		return nil, err
 	}
@@ -118,63 +187,66 @@ func (d *Database) GetClassifications() ([]proc.ClassificationResult, error) {
 
 	var results []proc.ClassificationResult
 	for rows.Next() {
-		var result proc.ClassificationResult
-		var matchesJSON string
-		err := rows.Scan(&result.Item, &result.Category, &result.Confidence, &matchesJSON)
+		result, err := scanClassification(rows)
 		if err != nil {
 			return nil, err
 		}
-		if err := json.Unmarshal([]byte(matchesJSON), &result.Matches); err != nil {
-			return nil, err
-		}
 		results = append(results, result)
 	}
 	return results, rows.Err()
 }
 
 func (d *Database) GetClassification(item string) (proc.ClassificationResult, error) {
-	var result proc.ClassificationResult
-	var matchesJSON string
-	err := d.db.QueryRow(
-		"SELECT item, category, confidence, matches FROM classifications WHERE item = ?",
+	return scanClassification(d.db.QueryRow(
+		"SELECT "+classificationColumns+" FROM classifications WHERE item = ?",
 		item,
-	).Scan(&result.Item, &result.Category, &result.Confidence, &matchesJSON)
+	))
+}
+
+func scanClassification(row scanner) (proc.ClassificationResult, error) {
+	var result proc.ClassificationResult
+	var matchesJSON, pathJSON string
+	err := row.Scan(
+		&result.Item, &result.Category, &result.Confidence, &matchesJSON, &pathJSON, &result.Score,
+		&result.Abstained, &result.AbstainReason,
+	)
 	if err != nil {
 		return proc.ClassificationResult{}, err
 	}
 	if err := json.Unmarshal([]byte(matchesJSON), &result.Matches); err != nil {
 		return proc.ClassificationResult{}, err
 	}
+	if err := json.Unmarshal([]byte(pathJSON), &result.Path); err != nil {
+		return proc.ClassificationResult{}, err
+	}
 	return result, nil
 }
 
+const categoryColumns = "name, keywords, phrases, contexts, excluders, languages, weights, patterns, fuzzy, expression, parent, min_confidence, min_matches"
+
 func (d *Database) AddCategory(category proc.Category) error {
-	keywords, err := json.Marshal(category.Keywords)
-	if err != nil {
-		return err
-	}
-	phrases, err := json.Marshal(category.Phrases)
-	if err != nil {
-		return err
-	}
-	contexts, err := json.Marshal(category.Contexts)
-	if err != nil {
-		return err
-	}
-	excluders, err := json.Marshal(category.Excluders)
-	if err != nil {
-		return err
+	values := []any{category.Name}
+	for _, field := range []any{
+		category.Keywords, category.Phrases, category.Contexts, category.Excluders, category.Languages,
+		category.Weights, category.Patterns,
+	} {
+		data, err := json.Marshal(field)
+		if err != nil {
+			return err
+		}
+		values = append(values, string(data))
 	}
+	values = append(values, category.Fuzzy, category.Expression, category.Parent, category.MinConfidence, category.MinMatches)
 
-	_, err = d.db.Exec(
-		"INSERT OR REPLACE INTO categories (name, keywords, phrases, contexts, excluders) VALUES (?, ?, ?, ?, ?)",
-		category.Name, string(keywords), string(phrases), string(contexts), string(excluders),
+	_, err := d.db.Exec(
+		"INSERT OR REPLACE INTO categories ("+categoryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		values...,
 	)
 	return err
 }
 
 func (d *Database) GetCategories() ([]proc.Category, error) {
-	rows, err := d.db.Query("SELECT name, keywords, phrases, contexts, excluders FROM categories")
+	rows, err := d.db.Query("SELECT " + categoryColumns + " FROM categories")
 	if err != nil {
 		return nil, err
 	}
@@ -182,38 +254,33 @@ func (d *Database) GetCategories() ([]proc.Category, error) {
 
 	var categories []proc.Category
 	for rows.Next() {
-		var cat proc.Category
-		var keywordsJSON, phrasesJSON, contextsJSON, excludersJSON string
-		err := rows.Scan(&cat.Name, &keywordsJSON, &phrasesJSON, &contextsJSON, &excludersJSON)
+		cat, err := scanCategory(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := json.Unmarshal([]byte(keywordsJSON), &cat.Keywords); err != nil {
-			return nil, err
-		}
-		if err := json.Unmarshal([]byte(phrasesJSON), &cat.Phrases); err != nil {
-			return nil, err
-		}
-		if err := json.Unmarshal([]byte(contextsJSON), &cat.Contexts); err != nil {
-			return nil, err
-		}
-		if err := json.Unmarshal([]byte(excludersJSON), &cat.Excluders); err != nil {
-			return nil, err
-		}
-
 		categories = append(categories, cat)
 	}
 	return categories, rows.Err()
 }
 
 func (d *Database) GetCategory(name string) (proc.Category, error) {
-	var cat proc.Category
-	var keywordsJSON, phrasesJSON, contextsJSON, excludersJSON string
-	err := d.db.QueryRow(
-		"SELECT name, keywords, phrases, contexts, excluders FROM categories WHERE name = ?",
+	return scanCategory(d.db.QueryRow(
+		"SELECT "+categoryColumns+" FROM categories WHERE name = ?",
 		name,
-	).Scan(&cat.Name, &keywordsJSON, &phrasesJSON, &contextsJSON, &excludersJSON)
+	))
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCategory(row scanner) (proc.Category, error) {
+	var cat proc.Category
+	var keywordsJSON, phrasesJSON, contextsJSON, excludersJSON, languagesJSON, weightsJSON, patternsJSON string
+	err := row.Scan(
+		&cat.Name, &keywordsJSON, &phrasesJSON, &contextsJSON, &excludersJSON, &languagesJSON, &weightsJSON,
+		&patternsJSON, &cat.Fuzzy, &cat.Expression, &cat.Parent, &cat.MinConfidence, &cat.MinMatches,
+	)
 	if err != nil {
 		return proc.Category{}, err
 	}
@@ -230,14 +297,139 @@ func (d *Database) GetCategory(name string) (proc.Category, error) {
 	if err := json.Unmarshal([]byte(excludersJSON), &cat.Excluders); err != nil {
 		return proc.Category{}, err
 	}
+	if err := json.Unmarshal([]byte(languagesJSON), &cat.Languages); err != nil {
+		return proc.Category{}, err
+	}
+	if err := json.Unmarshal([]byte(weightsJSON), &cat.Weights); err != nil {
+		return proc.Category{}, err
+	}
+	if err := json.Unmarshal([]byte(patternsJSON), &cat.Patterns); err != nil {
+		return proc.Category{}, err
+	}
 
 	return cat, nil
 }
 
+func (d *Database) AddExample(example proc.Example) error {
+	_, err := d.db.Exec(
+		"INSERT INTO examples (text, category) VALUES (?, ?)",
+		example.Text, example.Category,
+	)
+	return err
+}
+
+func (d *Database) GetExamples() ([]proc.Example, error) {
+	rows, err := d.db.Query("SELECT text, category FROM examples ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var examples []proc.Example
+	for rows.Next() {
+		var example proc.Example
+		if err := rows.Scan(&example.Text, &example.Category); err != nil {
+			return nil, err
+		}
+		examples = append(examples, example)
+	}
+	return examples, rows.Err()
+}
+
+func (d *Database) AddLexicon(lexicon proc.Lexicon) error {
+	termsJSON, err := json.Marshal(lexicon.Terms)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(
+		"INSERT OR REPLACE INTO lexicons (name, terms) VALUES (?, ?)",
+		lexicon.Name, string(termsJSON),
+	)
+	return err
+}
+
+func (d *Database) GetLexicons() ([]proc.Lexicon, error) {
+	rows, err := d.db.Query("SELECT name, terms FROM lexicons ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lexicons []proc.Lexicon
+	for rows.Next() {
+		lexicon, err := scanLexicon(rows)
+		if err != nil {
+			return nil, err
+		}
+		lexicons = append(lexicons, lexicon)
+	}
+	return lexicons, rows.Err()
+}
+
+func (d *Database) GetLexicon(name string) (proc.Lexicon, error) {
+	return scanLexicon(d.db.QueryRow("SELECT name, terms FROM lexicons WHERE name = ?", name))
+}
+
+func scanLexicon(row scanner) (proc.Lexicon, error) {
+	var lexicon proc.Lexicon
+	var termsJSON string
+	if err := row.Scan(&lexicon.Name, &termsJSON); err != nil {
+		return proc.Lexicon{}, err
+	}
+	if err := json.Unmarshal([]byte(termsJSON), &lexicon.Terms); err != nil {
+		return proc.Lexicon{}, err
+	}
+	return lexicon, nil
+}
+
+func (d *Database) DeleteLexicon(name string) error {
+	_, err := d.db.Exec("DELETE FROM lexicons WHERE name = ?", name)
+	return err
+}
+
+func (d *Database) AddFeedback(feedback proc.Feedback) error {
+	_, err := d.db.Exec(
+		"INSERT INTO feedback (item, category, predicted, reviewer, created_at) VALUES (?, ?, ?, ?, ?)",
+		feedback.Item, feedback.Category, feedback.Predicted, feedback.Reviewer, feedback.CreatedAt,
+	)
+	return err
+}
+
+// GetFeedback returns all feedback, oldest first.
+func (d *Database) GetFeedback() ([]proc.Feedback, error) {
+	return d.queryFeedback("SELECT item, category, predicted, reviewer, created_at FROM feedback ORDER BY id")
+}
+
+func (d *Database) GetItemFeedback(item string) ([]proc.Feedback, error) {
+	return d.queryFeedback("SELECT item, category, predicted, reviewer, created_at FROM feedback WHERE item = ? ORDER BY id", item)
+}
+
+func (d *Database) queryFeedback(query string, args ...any) ([]proc.Feedback, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feedback []proc.Feedback
+	for rows.Next() {
+		var f proc.Feedback
+		if err := rows.Scan(&f.Item, &f.Category, &f.Predicted, &f.Reviewer, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		feedback = append(feedback, f)
+	}
+	return feedback, rows.Err()
+}
+
 func (d *Database) Cleanup() error {
 	_, err := d.db.Exec(`
 		DELETE FROM classifications WHERE item LIKE 'test%';
+		DELETE FROM feedback WHERE item LIKE 'test%';
 		DELETE FROM categories WHERE name LIKE 'Test%';
+		DELETE FROM examples WHERE category LIKE 'Test%';
+		DELETE FROM lexicons WHERE name LIKE 'test%';
 	`)
 	return err
 }
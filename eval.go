@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+
+	"cfs/eval"
+	"cfs/server"
+)
+
+// runEval implements "cfs eval [-backend name] [-abstain threshold] [-json]
+// dataset", which classifies a labeled CSV or JSONL dataset with the stored
+// categories and examples and prints how well the results match the labels.
+func runEval(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	backend := flags.String("backend", server.BackendRules, "classifier to evaluate: rules, bayes or ensemble")
+	abstain := flags.Float64("abstain", 0, "confidence below which the classifier answers Unknown instead of its best category")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Usage = func() {
+		flags.Output().Write([]byte("usage: cfs eval [-backend name] [-abstain threshold] [-json] dataset.csv|dataset.jsonl\n"))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("eval needs exactly one dataset")
+	}
+
+	samples, err := eval.LoadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s := server.Server{Backend: *backend, AbstainThreshold: *abstain}
+	if err := s.Init(); err != nil {
+		return err
+	}
+	defer s.Close()
+	classifier, _ := s.Classifier("")
+
+	report := eval.Evaluate(classifier, samples)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(os.Stdout)
+}
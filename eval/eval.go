@@ -0,0 +1,263 @@
+// Package eval measures a classifier against a labeled dataset.
+package eval
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"cfs/proc"
+)
+
+const Unknown = "Unknown"
+
+// Sample is a text and the category it should be classified as. A label of
+// Unknown means no category should apply.
+type Sample struct {
+	Text  string `json:"text"`
+	Label string `json:"label"`
+}
+
+// LoadFile reads a dataset, as JSONL when the file ends in .jsonl or .json
+// and as CSV otherwise.
+func LoadFile(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		return LoadJSONL(f)
+	}
+	return LoadCSV(f)
+}
+
+// LoadCSV reads text,label rows. A first row of exactly "text,label" is
+// taken as a header.
+func LoadCSV(r io.Reader) ([]Sample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > 0 && records[0][0] == "text" && records[0][1] == "label" {
+		records = records[1:]
+	}
+
+	samples := make([]Sample, 0, len(records))
+	for i, record := range records {
+		sample := Sample{Text: record[0], Label: record[1]}
+		if err := sample.validate(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// LoadJSONL reads one {"text": ..., "label": ...} object per line, skipping
+// blank lines.
+func LoadJSONL(r io.Reader) ([]Sample, error) {
+	samples := make([]Sample, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var sample Sample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := sample.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+func (s Sample) validate() error {
+	if s.Text == "" {
+		return errors.New("text is required")
+	}
+	if s.Label == "" {
+		return errors.New("label is required")
+	}
+	return nil
+}
+
+type Metrics struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+type CategoryMetrics struct {
+	Category string `json:"category"`
+	Metrics
+	Support int `json:"support"`
+}
+
+type Misclassification struct {
+	Text       string  `json:"text"`
+	Label      string  `json:"label"`
+	Predicted  string  `json:"predicted"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Report holds the results of an evaluation. Unknown is a row and column of
+// the confusion matrix like any category, but it is not scored: predicting
+// Unknown for a labeled text only costs that label recall, and predicting a
+// category for an Unknown text only costs that category precision.
+type Report struct {
+	Total         int                       `json:"total"`
+	Accuracy      float64                   `json:"accuracy"`
+	Categories    []CategoryMetrics         `json:"categories"`
+	Macro         Metrics                   `json:"macro"`
+	Micro         Metrics                   `json:"micro"`
+	Labels        []string                  `json:"labels"`
+	Confusion     map[string]map[string]int `json:"confusion"`
+	Misclassified []Misclassification       `json:"misclassified"`
+}
+
+// Evaluate classifies every sample and compares the results to the labels.
+func Evaluate(classifier proc.Classifier, samples []Sample) Report {
+	report := Report{
+		Total:         len(samples),
+		Confusion:     make(map[string]map[string]int),
+		Misclassified: make([]Misclassification, 0),
+	}
+
+	labels := map[string]bool{}
+	correct := 0
+	for _, sample := range samples {
+		result := classifier.Classify(sample.Text)
+		labels[sample.Label] = true
+		labels[result.Category] = true
+		if report.Confusion[sample.Label] == nil {
+			report.Confusion[sample.Label] = make(map[string]int)
+		}
+		report.Confusion[sample.Label][result.Category]++
+
+		if result.Category == sample.Label {
+			correct++
+			continue
+		}
+		report.Misclassified = append(report.Misclassified, Misclassification{
+			Text:       sample.Text,
+			Label:      sample.Label,
+			Predicted:  result.Category,
+			Confidence: result.Confidence,
+		})
+	}
+	if len(samples) > 0 {
+		report.Accuracy = float64(correct) / float64(len(samples))
+	}
+
+	for label := range labels {
+		report.Labels = append(report.Labels, label)
+	}
+	sort.Strings(report.Labels)
+
+	truePositives, falsePositives, falseNegatives := 0, 0, 0
+	report.Categories = make([]CategoryMetrics, 0, len(report.Labels))
+	for _, category := range report.Labels {
+		if category == Unknown {
+			continue
+		}
+		tp := report.Confusion[category][category]
+		fp, fn := 0, 0
+		for _, label := range report.Labels {
+			if label != category {
+				fp += report.Confusion[label][category]
+				fn += report.Confusion[category][label]
+			}
+		}
+		truePositives += tp
+		falsePositives += fp
+		falseNegatives += fn
+
+		m := CategoryMetrics{Category: category, Metrics: metrics(tp, fp, fn), Support: tp + fn}
+		report.Categories = append(report.Categories, m)
+		report.Macro.Precision += m.Precision
+		report.Macro.Recall += m.Recall
+		report.Macro.F1 += m.F1
+	}
+
+	if n := float64(len(report.Categories)); n > 0 {
+		report.Macro.Precision /= n
+		report.Macro.Recall /= n
+		report.Macro.F1 /= n
+	}
+	report.Micro = metrics(truePositives, falsePositives, falseNegatives)
+	return report
+}
+
+func metrics(tp, fp, fn int) Metrics {
+	var m Metrics
+	if tp+fp > 0 {
+		m.Precision = float64(tp) / float64(tp+fp)
+	}
+	if tp+fn > 0 {
+		m.Recall = float64(tp) / float64(tp+fn)
+	}
+	if m.Precision+m.Recall > 0 {
+		m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+	}
+	return m
+}
+
+// WriteText prints the report as aligned tables.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "category\tprecision\trecall\tf1\tsupport\n")
+	for _, c := range r.Categories {
+		fmt.Fprintf(tw, "%s\t%.3f\t%.3f\t%.3f\t%d\n", c.Category, c.Precision, c.Recall, c.F1, c.Support)
+	}
+	fmt.Fprintf(tw, "macro\t%.3f\t%.3f\t%.3f\t\n", r.Macro.Precision, r.Macro.Recall, r.Macro.F1)
+	fmt.Fprintf(tw, "micro\t%.3f\t%.3f\t%.3f\t\n", r.Micro.Precision, r.Micro.Recall, r.Micro.F1)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\naccuracy %.3f over %d samples\n\n", r.Accuracy, r.Total)
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "label \\ predicted")
+	for _, predicted := range r.Labels {
+		fmt.Fprintf(tw, "\t%s", predicted)
+	}
+	fmt.Fprintln(tw)
+	for _, label := range r.Labels {
+		fmt.Fprint(tw, label)
+		for _, predicted := range r.Labels {
+			fmt.Fprintf(tw, "\t%d", r.Confusion[label][predicted])
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Misclassified) > 0 {
+		fmt.Fprintf(w, "\nmisclassified:\n")
+	}
+	for _, m := range r.Misclassified {
+		if _, err := fmt.Fprintf(w, "  %q: want %s, got %s (%.3f)\n", m.Text, m.Label, m.Predicted, m.Confidence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package eval
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"cfs/proc"
+)
+
+func TestLoad(t *testing.T) {
+	csvSamples, err := LoadCSV(strings.NewReader("text,label\n\"Fresh pasta, made today\",Food\nNew laptop,Technology\n"))
+	if err != nil {
+		t.Fatalf("LoadCSV() error = %v", err)
+	}
+	if len(csvSamples) != 2 || csvSamples[0].Text != "Fresh pasta, made today" || csvSamples[1].Label != "Technology" {
+		t.Errorf("LoadCSV() = %+v", csvSamples)
+	}
+
+	jsonlSamples, err := LoadJSONL(strings.NewReader(`{"text":"Fresh pasta","label":"Food"}` + "\n\n" + `{"text":"New laptop","label":"Technology"}` + "\n"))
+	if err != nil {
+		t.Fatalf("LoadJSONL() error = %v", err)
+	}
+	if len(jsonlSamples) != 2 || jsonlSamples[1].Text != "New laptop" {
+		t.Errorf("LoadJSONL() = %+v", jsonlSamples)
+	}
+
+	if _, err := LoadJSONL(strings.NewReader(`{"text":"No label"}`)); err == nil {
+		t.Error("LoadJSONL() without a label error = nil, want error")
+	}
+	if _, err := LoadCSV(strings.NewReader("only one column\n")); err == nil {
+		t.Error("LoadCSV() with one column error = nil, want error")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	classifier := &proc.RuleClassifier{}
+	classifier.Init([]proc.Category{
+		{Name: "Technology", Keywords: proc.Terms("computer", "software")},
+		{Name: "Food", Keywords: proc.Terms("recipe", "pasta")},
+	})
+
+	samples := []Sample{
+		{Text: "A new computer", Label: "Technology"},
+		{Text: "Software updates", Label: "Technology"},
+		{Text: "Cloud hosting", Label: "Technology"},
+		{Text: "A pasta recipe", Label: "Food"},
+		{Text: "Pasta for the computer lab", Label: "Food"},
+		{Text: "Sunny weather", Label: Unknown},
+	}
+
+	report := Evaluate(classifier, samples)
+
+	if report.Total != 6 || math.Abs(report.Accuracy-4.0/6) > 1e-9 {
+		t.Errorf("Evaluate() accuracy = %v over %d", report.Accuracy, report.Total)
+	}
+	if report.Confusion["Technology"][Unknown] != 1 || report.Confusion["Technology"]["Technology"] != 2 {
+		t.Errorf("Evaluate() confusion = %v", report.Confusion)
+	}
+	if len(report.Misclassified) != 2 {
+		t.Errorf("Evaluate() misclassified = %+v, want 2", report.Misclassified)
+	}
+
+	expected := map[string]CategoryMetrics{
+		"Food":       {Category: "Food", Metrics: metrics(1, 0, 1), Support: 2},
+		"Technology": {Category: "Technology", Metrics: metrics(2, 1, 1), Support: 3},
+	}
+	if len(report.Categories) != 2 {
+		t.Fatalf("Evaluate() categories = %+v", report.Categories)
+	}
+	for _, c := range report.Categories {
+		if c != expected[c.Category] {
+			t.Errorf("Evaluate() %s = %+v, want %+v", c.Category, c, expected[c.Category])
+		}
+	}
+	if micro := metrics(3, 1, 2); report.Micro != micro {
+		t.Errorf("Evaluate() micro = %+v, want %+v", report.Micro, micro)
+	}
+	if macroF1 := (expected["Food"].F1 + expected["Technology"].F1) / 2; math.Abs(report.Macro.F1-macroF1) > 1e-9 {
+		t.Errorf("Evaluate() macro F1 = %v, want %v", report.Macro.F1, macroF1)
+	}
+
+	var out bytes.Buffer
+	if err := report.WriteText(&out); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "label \\ predicted") || !strings.Contains(out.String(), `"Cloud hosting": want Technology, got Unknown`) {
+		t.Errorf("WriteText() = %s", out.String())
+	}
+}
@@ -3,3 +3,5 @@ module cfs
 go 1.23.3
 
 require github.com/mattn/go-sqlite3 v1.14.24
+
+require golang.org/x/text v0.21.0
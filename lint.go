@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"cfs/db"
+	"cfs/proc"
+)
+
+// runLint implements "cfs lint [-json]", which checks the stored categories,
+// with their lexicons expanded, for conflicting and dead rules. It fails when
+// any finding is an error.
+func runLint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the findings as JSON")
+	flags.Usage = func() {
+		flags.Output().Write([]byte("usage: cfs lint [-json]\n"))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	d := db.Database{}
+	if err := d.Init(); err != nil {
+		return err
+	}
+	defer d.Close()
+	d.Seed()
+
+	categories, err := d.GetCategories()
+	if err != nil {
+		return err
+	}
+	lexicons, err := d.GetLexicons()
+	if err != nil {
+		return err
+	}
+	categories, err = proc.ExpandLexicons(categories, lexicons)
+	if err != nil {
+		return err
+	}
+
+	findings := proc.Lint(categories)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(findings); err != nil {
+			return err
+		}
+	} else {
+		for _, finding := range findings {
+			fmt.Println(finding)
+		}
+	}
+
+	for _, finding := range findings {
+		if finding.Severity == proc.SeverityError {
+			return errors.New("lint found errors")
+		}
+	}
+	return nil
+}
@@ -1,12 +1,38 @@
 package main
 
 import (
+	"flag"
+	"log"
+	"os"
+
 	"cfs/server"
 )
 
 func main() {
-	server := server.Server{}
-	server.Init()
+	if len(os.Args) > 1 {
+		var run func([]string) error
+		switch os.Args[1] {
+		case "eval":
+			run = runEval
+		case "lint":
+			run = runLint
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	backend := flag.String("backend", server.BackendRules, "classifier used when a request does not pick one: rules, bayes or ensemble")
+	abstain := flag.Float64("abstain", 0, "confidence below which the classifier answers Unknown instead of its best category")
+	flag.Parse()
+
+	server := server.Server{Backend: *backend, AbstainThreshold: *abstain}
+	if err := server.Init(); err != nil {
+		log.Fatal(err)
+	}
 	defer server.Close()
 
 	server.Run()
@@ -0,0 +1,180 @@
+package proc
+
+import (
+	"math"
+	"sort"
+)
+
+var _ Classifier = (*NaiveBayes)(nil)
+
+// NaiveBayes is a multinomial Naive Bayes model trained from labeled
+// examples. Texts are normalized with the default language's pipeline and
+// stop words are ignored. Word counts are smoothed with add-one (Laplace)
+// smoothing, so words never seen with a category do not rule it out.
+//
+// Confidence is the posterior probability of the category given the words of
+// the text the model knows, and Score its log, up to a constant shared by
+// all categories of the same text.
+type NaiveBayes struct {
+	pipeline   Pipeline
+	categories []string
+	priors     []float64
+	counts     map[string][]float64
+	totals     []float64
+}
+
+func (nb *NaiveBayes) Init(examples []Example) {
+	nb.pipeline = DefaultPipeline()
+	nb.counts = make(map[string][]float64)
+
+	index := make(map[string]int)
+	for _, example := range examples {
+		if _, ok := index[example.Category]; !ok {
+			index[example.Category] = len(index)
+		}
+	}
+	nb.categories = make([]string, len(index))
+	for category, i := range index {
+		nb.categories[i] = category
+	}
+	sort.Strings(nb.categories)
+	for i, category := range nb.categories {
+		index[category] = i
+	}
+
+	documents := make([]float64, len(nb.categories))
+	nb.totals = make([]float64, len(nb.categories))
+	for _, example := range examples {
+		c := index[example.Category]
+		documents[c]++
+		for _, token := range nb.pipeline.Tokenize(example.Text) {
+			if token.Stop {
+				continue
+			}
+			if nb.counts[token.Text] == nil {
+				nb.counts[token.Text] = make([]float64, len(nb.categories))
+			}
+			nb.counts[token.Text][c]++
+			nb.totals[c]++
+		}
+	}
+
+	nb.priors = make([]float64, len(nb.categories))
+	for c := range nb.categories {
+		nb.priors[c] = math.Log(documents[c] / float64(len(examples)))
+	}
+}
+
+func (nb *NaiveBayes) likelihood(word string, c int) float64 {
+	return math.Log((nb.counts[word][c] + 1) / (nb.totals[c] + float64(len(nb.counts))))
+}
+
+func (nb *NaiveBayes) Classify(sentence string) ClassificationResult {
+	return nb.ClassifyWith(sentence, ClassifyOptions{})
+}
+
+func (nb *NaiveBayes) ClassifyWith(sentence string, opts ClassifyOptions) ClassificationResult {
+	ranked := nb.rank(sentence)
+	if len(ranked) == 0 {
+		return ClassificationResult{
+			Category:   "Unknown",
+			Confidence: 0.0,
+			Matches:    nil,
+			Language:   DefaultLanguage,
+		}
+	}
+
+	result := ranked[0]
+	if opts.MultiLabel() {
+		result.Labels = make([]Label, 0)
+		for _, label := range filterRanked(ranked, opts) {
+			result.Labels = append(result.Labels, Label{
+				Category:   label.Category,
+				Confidence: label.Confidence,
+				Score:      label.Score,
+				Matches:    label.Matches,
+			})
+		}
+	}
+	return result
+}
+
+func (nb *NaiveBayes) Rank(sentence string, opts ClassifyOptions) []ClassificationResult {
+	return filterRanked(nb.rank(sentence), opts)
+}
+
+// rank returns every category ordered by posterior probability, or nothing
+// when the text has no word the model has seen.
+func (nb *NaiveBayes) rank(sentence string) []ClassificationResult {
+	doc := document{text: sentence, tokens: nb.pipeline.Tokenize(sentence)}
+	words := make([]string, 0)
+	first := make(map[string]Token)
+	for _, token := range doc.tokens {
+		if token.Stop || nb.counts[token.Text] == nil {
+			continue
+		}
+		if _, ok := first[token.Text]; !ok {
+			first[token.Text] = token
+		}
+		words = append(words, token.Text)
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	scores := make([]float64, len(nb.categories))
+	best := math.Inf(-1)
+	for c := range nb.categories {
+		scores[c] = nb.priors[c]
+		for _, word := range words {
+			scores[c] += nb.likelihood(word, c)
+		}
+		best = math.Max(best, scores[c])
+	}
+	total := 0.0
+	for _, score := range scores {
+		total += math.Exp(score - best)
+	}
+
+	results := make([]ClassificationResult, len(nb.categories))
+	for c, category := range nb.categories {
+		results[c] = ClassificationResult{
+			Category:   category,
+			Confidence: math.Exp(scores[c]-best) / total,
+			Score:      scores[c],
+			Matches:    nb.evidence(doc, words, first, c),
+			Language:   DefaultLanguage,
+		}
+	}
+	sortRanked(results)
+	return results
+}
+
+// evidence lists the words that favor category c over the average category,
+// scored by how much more likely they make it.
+func (nb *NaiveBayes) evidence(doc document, words []string, first map[string]Token, c int) []Match {
+	occurrences := make(map[string]int)
+	for _, word := range words {
+		occurrences[word]++
+	}
+
+	matches := make([]Match, 0)
+	for _, word := range words {
+		if occurrences[word] == 0 {
+			continue
+		}
+		mean := 0.0
+		for other := range nb.categories {
+			mean += nb.likelihood(word, other)
+		}
+		mean /= float64(len(nb.categories))
+		if lift := nb.likelihood(word, c) - mean; lift > 0 {
+			token := first[word]
+			match := doc.span(MatchFeature, word, token.Start, token.End)
+			match.Score = lift * float64(occurrences[word])
+			matches = append(matches, match)
+		}
+		occurrences[word] = 0
+	}
+	return matches
+}
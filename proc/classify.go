@@ -2,117 +2,466 @@ package proc
 
 import (
 	"fmt"
-	"strings"
-	"unicode"
+	"sort"
+	"sync"
 )
 
-type Classifier struct {
+// Classifier is a classification backend.
+type Classifier interface {
+	Classify(sentence string) ClassificationResult
+	ClassifyWith(sentence string, opts ClassifyOptions) ClassificationResult
+	Rank(sentence string, opts ClassifyOptions) []ClassificationResult
+}
+
+var _ Classifier = (*RuleClassifier)(nil)
+
+// RuleClassifier classifies text with the hand-written rules of each category.
+type RuleClassifier struct {
 	categories []Category
-	stopWords  map[string]bool
+	paths      [][]string
+	pipeline   Pipeline
+	language   string
+	weights    WeightProfile
+	negation   NegationConfig
+	scale      float64
+	abstain    float64
+	lexicons   map[string][]string
+
+	mu       sync.Mutex
+	ruleSets map[string]*ruleSet
 }
 
-func (sc *Classifier) Init(categories []Category) {
-	sc.categories = categories
-	sc.stopWords = makeStopWords()
+type Option func(*RuleClassifier)
+
+// WithPipeline replaces the per-language normalization pipelines with a
+// single one used for every language.
+func WithPipeline(pipeline Pipeline) Option {
+	return func(sc *RuleClassifier) {
+		sc.pipeline = pipeline
+	}
 }
 
-func makeStopWords() map[string]bool {
-	words := []string{"the", "is", "at", "which", "on", "a", "an", "and", "or", "but", "in", "with", "to", "for"}
-	stopWords := make(map[string]bool)
-	for _, word := range words {
-		stopWords[word] = true
+// WithWeights replaces DefaultWeightProfile as the weights used for
+// categories that do not override them.
+func WithWeights(profile WeightProfile) Option {
+	return func(sc *RuleClassifier) {
+		sc.weights = profile
 	}
-	return stopWords
 }
 
-func (sc *Classifier) tokenize(text string) []string {
-	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
-		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
-	})
+// WithNegation replaces DefaultNegation. A zero Window disables negation.
+func WithNegation(negation NegationConfig) Option {
+	return func(sc *RuleClassifier) {
+		sc.negation = negation
+	}
+}
+
+// WithConfidenceScale replaces DefaultConfidenceScale, the raw score per
+// square root of the word count at which confidence reaches 0.5. Scales
+// that are not positive keep the default.
+func WithConfidenceScale(scale float64) Option {
+	return func(sc *RuleClassifier) {
+		sc.scale = scale
+	}
+}
+
+// WithAbstainThreshold makes Classify answer Unknown, with Abstained set,
+// when the best category's confidence is below threshold.
+func WithAbstainThreshold(threshold float64) Option {
+	return func(sc *RuleClassifier) {
+		sc.abstain = threshold
+	}
+}
+
+// WithLexicons supplies the lexicons that "@name" terms of the categories
+// expand to. References to lexicons not given match nothing.
+func WithLexicons(lexicons []Lexicon) Option {
+	return func(sc *RuleClassifier) {
+		sc.lexicons = lexiconMap(lexicons)
+	}
+}
+
+// WithLanguage sets the language used when a request does not name one.
+// AutoLanguage detects it from each input instead.
+func WithLanguage(language string) Option {
+	return func(sc *RuleClassifier) {
+		sc.language = language
+	}
+}
+
+func (sc *RuleClassifier) Init(categories []Category, options ...Option) {
+	sc.paths = categoryPaths(categories)
+	sc.categories = inherit(categories, sc.paths)
+	sc.pipeline = nil
+	sc.language = DefaultLanguage
+	sc.weights = DefaultWeightProfile
+	sc.negation = DefaultNegation
+	sc.scale = DefaultConfidenceScale
+	sc.abstain = 0
+	sc.lexicons = nil
+	for _, option := range options {
+		option(sc)
+	}
+	if sc.scale <= 0 {
+		sc.scale = DefaultConfidenceScale
+	}
+	sc.categories = expandLexicons(sc.categories, sc.lexicons)
+	sc.ruleSets = make(map[string]*ruleSet)
+	sc.ruleSetFor(DefaultLanguage)
+}
+
+// ruleSetFor returns the compiled rules for a language, compiling them on
+// first use. Unknown languages fall back to the default language.
+func (sc *RuleClassifier) ruleSetFor(language string) *ruleSet {
+	registered, ok := LookupLanguage(language)
+	if !ok {
+		language = DefaultLanguage
+		registered, _ = LookupLanguage(language)
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if rs, ok := sc.ruleSets[language]; ok {
+		return rs
+	}
+
+	pipeline := sc.pipeline
+	if pipeline == nil {
+		pipeline = registered.Pipeline()
+	}
+	rs := sc.compileRuleSet(registered, pipeline)
+	sc.ruleSets[language] = rs
+	return rs
+}
+
+func (sc *RuleClassifier) resolveLanguage(sentence string, language string) string {
+	if language == "" {
+		language = sc.language
+	}
+	if language == AutoLanguage {
+		return DetectLanguage(sentence, DefaultLanguage)
+	}
+	return language
+}
+
+func countWords(tokens []Token) int {
+	count := 0
+	for _, token := range tokens {
+		if !token.Stop {
+			count++
+		}
+	}
+	return count
+}
+
+func (sc *RuleClassifier) Classify(sentence string) ClassificationResult {
+	return sc.ClassifyWith(sentence, ClassifyOptions{})
+}
 
-	filtered := make([]string, 0)
-	for _, word := range words {
-		if !sc.stopWords[word] {
-			filtered = append(filtered, word)
+// ClassifyWith returns the best category for the sentence. When opts asks for
+// multiple labels, every ranked category that passes them is listed in Labels.
+func (sc *RuleClassifier) ClassifyWith(sentence string, opts ClassifyOptions) ClassificationResult {
+	rs := sc.ruleSetFor(sc.resolveLanguage(sentence, opts.Language))
+	ranked, held := sc.rank(rs, sentence)
+	ranked = rollUp(ranked, opts.Depth)
+	unknown := ClassificationResult{
+		Category:   "Unknown",
+		Confidence: 0.0,
+		Matches:    nil,
+		Language:   rs.language,
+	}
+	if len(ranked) == 0 {
+		if len(held) > 0 {
+			unknown.Matches = held[0].Matches
+			unknown.Abstained = true
+			unknown.AbstainReason = held[0].AbstainReason
+		}
+		return unknown
+	}
+	if best := Abstain(ranked[0], sc.abstain); best.Abstained {
+		return best
+	}
+
+	result := ranked[0]
+	if opts.MultiLabel() {
+		result.Labels = make([]Label, 0)
+		for _, label := range filterRanked(ranked, opts) {
+			result.Labels = append(result.Labels, Label{
+				Category:   label.Category,
+				Path:       label.Path,
+				Confidence: label.Confidence,
+				Score:      label.Score,
+				Matches:    label.Matches,
+			})
 		}
 	}
+	return result
+}
+
+// Abstain returns Unknown, with Abstained set, in place of a result whose
+// confidence is below threshold. Other results are returned unchanged.
+func Abstain(result ClassificationResult, threshold float64) ClassificationResult {
+	if result.Category == "Unknown" || result.Confidence >= threshold {
+		return result
+	}
+	return ClassificationResult{
+		Category:      "Unknown",
+		Confidence:    0.0,
+		Matches:       nil,
+		Language:      result.Language,
+		Votes:         result.Votes,
+		Abstained:     true,
+		AbstainReason: fmt.Sprintf("%s: confidence %.2f is below the abstain threshold %.2f", result.Category, result.Confidence, threshold),
+	}
+}
+
+// Rank returns the categories matching the sentence ordered by confidence,
+// limited to those above opts.Threshold and to the first opts.TopN.
+func (sc *RuleClassifier) Rank(sentence string, opts ClassifyOptions) []ClassificationResult {
+	rs := sc.ruleSetFor(sc.resolveLanguage(sentence, opts.Language))
+	ranked, _ := sc.rank(rs, sentence)
+	return filterRanked(rollUp(ranked, opts.Depth), opts)
+}
+
+// rollUp replaces every result deeper than depth with its ancestor at that
+// depth, keeping the best result for each ancestor.
+func rollUp(ranked []ClassificationResult, depth int) []ClassificationResult {
+	if depth <= 0 {
+		return ranked
+	}
+	rolled := make([]ClassificationResult, 0, len(ranked))
+	seen := make(map[string]bool)
+	for _, result := range ranked {
+		if len(result.Path) > depth {
+			result.Path = result.Path[:depth]
+			result.Category = result.Path[depth-1]
+		}
+		if seen[result.Category] {
+			continue
+		}
+		seen[result.Category] = true
+		rolled = append(rolled, result)
+	}
+	return rolled
+}
+
+func filterRanked(ranked []ClassificationResult, opts ClassifyOptions) []ClassificationResult {
+	filtered := make([]ClassificationResult, 0, len(ranked))
+	for _, result := range ranked {
+		if result.Confidence < opts.Threshold {
+			break
+		}
+		filtered = append(filtered, result)
+	}
+	if opts.TopN > 0 && len(filtered) > opts.TopN {
+		filtered = filtered[:opts.TopN]
+	}
 	return filtered
 }
 
-func (sc *Classifier) Classify(sentence string) ClassificationResult {
-	words := sc.tokenize(sentence)
-	sentenceLower := strings.ToLower(sentence)
+// document is an input text together with its normalized tokens.
+type document struct {
+	text   string
+	tokens []Token
+	words  int
+}
 
-	results := make([]ClassificationResult, 0)
+// rank scores every matching category. Categories that fall short of their
+// own minimums are returned separately, with the reason set.
+func (sc *RuleClassifier) rank(rs *ruleSet, sentence string) ([]ClassificationResult, []ClassificationResult) {
+	tokens := rs.pipeline.Tokenize(sentence)
+	doc := document{text: sentence, tokens: tokens, words: countWords(tokens)}
 
-	for _, category := range sc.categories {
-		score := 0.0
-		matches := make([]string, 0)
+	hits := rs.matcher.scan(tokens)
+	hits = append(hits, rs.matcher.scanSubstrings(sentence)...)
+	hits = append(hits, rs.scanPatterns(sentence)...)
 
-		// excluded
-		excluded := false
-		for _, excluder := range category.Excluders {
-			if strings.Contains(sentenceLower, strings.ToLower(excluder)) {
-				excluded = true
-				break
-			}
+	matched := make(map[int]*ruleHits)
+	record := func(id int, h hit, fuzzy bool) {
+		negated := rs.negated(tokens, h)
+		rh, ok := matched[id]
+		switch {
+		case !ok:
+			matched[id] = &ruleHits{hits: []hit{h}, negated: negated, fuzzy: fuzzy}
+		case rh.fuzzy && !fuzzy:
+			*rh = ruleHits{hits: []hit{h}, negated: negated}
+		case rh.fuzzy == fuzzy:
+			rh.hits = append(rh.hits, h)
+			rh.negated = rh.negated && negated
+		}
+	}
+	for _, h := range hits {
+		for _, id := range h.rules {
+			record(id, h, false)
 		}
-		if excluded {
+	}
+	for i, token := range tokens {
+		if token.Stop {
 			continue
 		}
+		rs.fuzzy.search(token.Text, func(rule fuzzyRule, distance int) {
+			record(rule.id, hit{rules: []int{rule.id}, start: i, end: i + 1, from: token.Start, to: token.End}, true)
+		})
+	}
+	ids := make([]int, 0, len(matched))
+	for id := range matched {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
 
-		// keywords
-		for _, keyword := range category.Keywords {
-			if strings.Contains(sentenceLower, strings.ToLower(keyword)) {
-				score += 1.0
-				matches = append(matches, keyword)
-			}
+	ranked := make([]ClassificationResult, 0)
+	held := make([]ClassificationResult, 0)
+	for start := 0; start < len(ids); {
+		category := rs.rules[ids[start]].category
+		end := start
+		for end < len(ids) && rs.rules[ids[end]].category == category {
+			end++
+		}
+		if result, ok := sc.score(rs, category, ids[start:end], matched, doc); ok && result.Abstained {
+			held = append(held, result)
+		} else if ok {
+			ranked = append(ranked, result)
 		}
+		start = end
+	}
 
-		// phrases
-		for _, phrase := range category.Phrases {
-			if strings.Contains(sentenceLower, strings.ToLower(phrase)) {
-				score += 2.0
-				matches = append(matches, phrase)
-			}
+	sortRanked(ranked)
+	sortRanked(held)
+	return ranked, held
+}
+
+func sortRanked(results []ClassificationResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Confidence != results[j].Confidence {
+			return results[i].Confidence > results[j].Confidence
 		}
+		return len(results[i].Path) < len(results[j].Path)
+	})
+}
 
-		// contextual
-		for context, relatedWords := range category.Contexts {
-			if strings.Contains(sentenceLower, context) {
-				for _, related := range relatedWords {
-					if strings.Contains(sentenceLower, related) {
-						score += 1.5
-						matches = append(matches, fmt.Sprintf("%s-%s", context, related))
-					}
-				}
-			}
+// firstHit returns the first occurrence of a rule that is not negated, or the
+// first occurrence when all of them are.
+func (rs *ruleSet) firstHit(doc document, rh *ruleHits) hit {
+	for _, h := range rh.hits {
+		if !rs.negated(doc.tokens, h) {
+			return h
 		}
+	}
+	return rh.hits[0]
+}
 
-		// normalize
-		confidence := score / float64(len(words))
-		if confidence > 0 {
-			results = append(results, ClassificationResult{
-				Category:   category.Name,
-				Confidence: confidence,
-				Matches:    matches,
-			})
+// ruleHits collects every occurrence of one rule in the input. negated is
+// set only when all of them fall inside a negation window, and fuzzy when
+// none of them is an exact match.
+type ruleHits struct {
+	hits    []hit
+	negated bool
+	fuzzy   bool
+}
+
+func (sc *RuleClassifier) score(rs *ruleSet, category int, ids []int, matched map[int]*ruleHits, doc document) (ClassificationResult, bool) {
+	score := 0.0
+	matches := make([]Match, 0)
+	distinct := 0
+
+	add := func(weight float64, match Match, negated bool) {
+		if negated {
+			weight *= rs.negationFactor
+			match.Negated = true
 		}
+		if weight > 0 {
+			distinct++
+		}
+		match.Score = weight
+		score += weight
+		matches = append(matches, match)
 	}
 
-	if len(results) > 0 {
-		bestResult := results[0]
-		for _, result := range results {
-			if result.Confidence > bestResult.Confidence {
-				bestResult = result
+	for _, id := range ids {
+		r := rs.rules[id]
+		switch r.kind {
+		case ruleExcluder:
+			if !matched[id].negated {
+				return ClassificationResult{}, false
+			}
+		case ruleKeyword, rulePhrase:
+			rh := matched[id]
+			matchType := MatchKeyword
+			if r.kind == rulePhrase {
+				matchType = MatchPhrase
+			}
+			h := rs.firstHit(doc, rh)
+			match := doc.span(matchType, r.text, h.from, h.to)
+			if rh.fuzzy {
+				match.Fuzzy = true
+				add(r.fuzzyWeight, match, rh.negated)
+			} else {
+				add(r.weight, match, rh.negated)
+			}
+		case rulePattern:
+			rh := matched[id]
+			h := rs.firstHit(doc, rh)
+			add(r.weight, doc.span(MatchPattern, r.text, h.from, h.to), rh.negated)
+		case ruleRelated:
+			context, ok := matched[r.context]
+			if !ok {
+				continue
+			}
+			if c, h, found, negated := rs.contextPair(doc, r, context, matched[id]); found {
+				rule := fmt.Sprintf("%s-%s", rs.rules[r.context].text, r.text)
+				add(r.weight, doc.span(MatchContext, rule, min(c.from, h.from), max(c.to, h.to)), negated)
 			}
 		}
-		return bestResult
 	}
 
-	return ClassificationResult{
-		Category:   "Unknown",
-		Confidence: 0.0,
-		Matches:    nil,
+	if id, ok := rs.expressions[category]; ok {
+		r := rs.rules[id]
+		if holds, hits, negated := r.expression.eval(matched); holds {
+			match := Match{Type: MatchExpression, Rule: r.text}
+			if len(hits) > 0 {
+				from, to := hits[0].from, hits[0].to
+				for _, h := range hits[1:] {
+					from, to = min(from, h.from), max(to, h.to)
+				}
+				match = doc.span(MatchExpression, r.text, from, to)
+			}
+			add(r.weight, match, negated)
+		}
+	}
+
+	c := sc.categories[category]
+	if score <= 0 {
+		// Matches that only scored nothing because they were negated are
+		// kept on a held result, so Unknown can explain itself.
+		for _, match := range matches {
+			if match.Negated {
+				return ClassificationResult{
+					Category:      c.Name,
+					Path:          sc.paths[category],
+					Matches:       matches,
+					Language:      rs.language,
+					Abstained:     true,
+					AbstainReason: fmt.Sprintf("%s: every match is negated", c.Name),
+				}, true
+			}
+		}
+		return ClassificationResult{}, false
+	}
+	result := ClassificationResult{
+		Category:   c.Name,
+		Path:       sc.paths[category],
+		Confidence: calibrate(score, doc.words, sc.scale),
+		Score:      score,
+		Matches:    matches,
+		Language:   rs.language,
+	}
+	switch {
+	case result.Confidence < c.MinConfidence:
+		result.Abstained = true
+		result.AbstainReason = fmt.Sprintf("%s: confidence %.2f is below its minimum %.2f", c.Name, result.Confidence, c.MinConfidence)
+	case distinct < c.MinMatches:
+		result.Abstained = true
+		result.AbstainReason = fmt.Sprintf("%s: %d of %d required distinct matches", c.Name, distinct, c.MinMatches)
 	}
+	return result, true
 }
@@ -1,6 +1,10 @@
 package proc
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -8,19 +12,19 @@ func TestClassifier(t *testing.T) {
 	categories := []Category{
 		{
 			Name:      "Technology",
-			Keywords:  []string{"computer", "software", "hardware"},
-			Phrases:   []string{"artificial intelligence", "machine learning"},
-			Contexts:  map[string][]string{"data": {"analysis", "processing"}},
-			Excluders: []string{"biology"},
+			Keywords:  Terms("computer", "software", "hardware"),
+			Phrases:   Terms("artificial intelligence", "machine learning"),
+			Contexts:  map[string]Context{"data": {Related: []string{"analysis", "processing"}}},
+			Excluders: Terms("biology"),
 		},
 		{
 			Name:     "Science",
-			Keywords: []string{"research", "experiment", "laboratory"},
-			Phrases:  []string{"scientific method", "hypothesis testing"},
+			Keywords: Terms("research", "experiment", "laboratory"),
+			Phrases:  Terms("scientific method", "hypothesis testing"),
 		},
 	}
 
-	classifier := &Classifier{}
+	classifier := &RuleClassifier{}
 	classifier.Init(categories)
 
 	tests := []struct {
@@ -92,3 +96,978 @@ func TestClassifier(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifierMultiLabel(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("computer", "software")},
+		{Name: "Science", Keywords: Terms("research", "experiment", "laboratory")},
+		{Name: "Sports", Keywords: Terms("football")},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	input := "computer research in the laboratory"
+
+	tests := []struct {
+		name     string
+		opts     ClassifyOptions
+		expected []string
+	}{
+		{
+			name:     "All labels",
+			opts:     ClassifyOptions{},
+			expected: []string{"Science", "Technology"},
+		},
+		{
+			name:     "Top N",
+			opts:     ClassifyOptions{TopN: 1},
+			expected: []string{"Science"},
+		},
+		{
+			name:     "Threshold",
+			opts:     ClassifyOptions{Threshold: 0.5},
+			expected: []string{"Science"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := classifier.Rank(input, tt.opts)
+			if len(ranked) != len(tt.expected) {
+				t.Fatalf("Rank() returned %d results, want %d", len(ranked), len(tt.expected))
+			}
+			for i, result := range ranked {
+				if result.Category != tt.expected[i] {
+					t.Errorf("Rank()[%d] category = %v, want %v", i, result.Category, tt.expected[i])
+				}
+			}
+		})
+	}
+
+	t.Run("Labels on result", func(t *testing.T) {
+		result := classifier.ClassifyWith(input, ClassifyOptions{TopN: 2})
+		if result.Category != "Science" {
+			t.Errorf("ClassifyWith() category = %v, want %v", result.Category, "Science")
+		}
+		if len(result.Labels) != 2 {
+			t.Errorf("ClassifyWith() returned %d labels, want 2", len(result.Labels))
+		}
+	})
+}
+
+func TestClassifierTokenBoundaries(t *testing.T) {
+	categories := []Category{
+		{
+			Name:      "Technology",
+			Keywords:  Terms("code", "software"),
+			Excluders: Terms("cook"),
+		},
+		{
+			Name:     "Security",
+			Keywords: []Term{{Text: "crypt", Match: MatchSubstring}},
+		},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		name             string
+		input            string
+		expectedCategory string
+	}{
+		{
+			name:             "Keyword inside a longer word",
+			input:            "Decode the message",
+			expectedCategory: "Unknown",
+		},
+		{
+			name:             "Excluder inside a longer word",
+			input:            "Software that tracks cookies",
+			expectedCategory: "Technology",
+		},
+		{
+			name:             "Excluder as a whole word",
+			input:            "Software to help you cook",
+			expectedCategory: "Unknown",
+		},
+		{
+			name:             "Explicit substring rule",
+			input:            "Cryptography basics",
+			expectedCategory: "Security",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := classifier.Classify(tt.input)
+			if result.Category != tt.expectedCategory {
+				t.Errorf("Classify() category = %v, want %v", result.Category, tt.expectedCategory)
+			}
+		})
+	}
+
+	t.Run("Substring offsets after case folding", func(t *testing.T) {
+		// The Kelvin sign lowercases to a shorter "k" and "İ" to a longer
+		// "i̇", so offsets into the lowercased text would be off.
+		for _, input := range []string{"\u212Arypto: crypt", "İİ crypt"} {
+			result := classifier.Classify(input)
+			want := strings.LastIndex(input, "crypt")
+			if len(result.Matches) != 1 || result.Matches[0].Text != "crypt" || result.Matches[0].Start != want {
+				t.Errorf("Classify(%q) matches = %+v, want crypt at %d", input, result.Matches, want)
+			}
+		}
+	})
+
+	t.Run("Term JSON round-trip", func(t *testing.T) {
+		data := []byte(`["code",{"term":"crypt","match":"substring"},{"term":"system","weight":0.3},{"term":"legacy","weight":0}]`)
+		var terms []Term
+		if err := json.Unmarshal(data, &terms); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if terms[0].Text != "code" || terms[1].Match != MatchSubstring || terms[2].Factor() != 0.3 || terms[3].Factor() != 0 {
+			t.Errorf("Unmarshal() = %+v", terms)
+		}
+		out, err := json.Marshal(terms)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(out) != string(data) {
+			t.Errorf("Marshal() = %s, want %s", out, data)
+		}
+	})
+}
+
+func TestClassifierOverlappingRules(t *testing.T) {
+	categories := []Category{
+		{Name: "Neural", Phrases: Terms("deep neural network")},
+		{Name: "Network", Keywords: Terms("network"), Phrases: Terms("neural network")},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	ranked := classifier.Rank("a deep neural network model", ClassifyOptions{})
+	if len(ranked) != 2 {
+		t.Fatalf("Rank() returned %d results, want 2", len(ranked))
+	}
+	for _, result := range ranked {
+		switch result.Category {
+		case "Neural":
+			if len(result.Matches) != 1 {
+				t.Errorf("Neural matches = %v, want 1", result.Matches)
+			}
+		case "Network":
+			if len(result.Matches) != 2 {
+				t.Errorf("Network matches = %v, want 2", result.Matches)
+			}
+		}
+	}
+}
+
+func BenchmarkClassify(b *testing.B) {
+	input := "The new software release improves cloud computing performance for " +
+		"data analysis across kw17a and kw512b systems while p42 x42 stays stable"
+
+	for _, size := range []int{10, 1000, 10000, 50000} {
+		categories := make([]Category, size)
+		for i := range categories {
+			categories[i] = Category{
+				Name:      fmt.Sprintf("Category%d", i),
+				Keywords:  Terms(fmt.Sprintf("kw%da", i), fmt.Sprintf("kw%db", i), fmt.Sprintf("kw%dc", i)),
+				Phrases:   Terms(fmt.Sprintf("p%d x%d", i, i)),
+				Contexts:  map[string]Context{fmt.Sprintf("ctx%d", i): {Related: []string{fmt.Sprintf("rel%d", i)}}},
+				Excluders: Terms(fmt.Sprintf("ex%d", i)),
+			}
+		}
+
+		classifier := &RuleClassifier{}
+		classifier.Init(categories)
+
+		b.Run(fmt.Sprintf("categories=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				classifier.Classify(input)
+			}
+		})
+	}
+}
+
+func TestClassifierLanguages(t *testing.T) {
+	categories := []Category{
+		{Name: "Programación", Keywords: Terms("programación"), Languages: []string{"es"}},
+		{Name: "Technology", Keywords: Terms("program"), Languages: []string{"en"}},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		name             string
+		input            string
+		language         string
+		expectedCategory string
+		expectedLanguage string
+	}{
+		{
+			name:             "Default language",
+			input:            "New programs for developers",
+			expectedCategory: "Technology",
+			expectedLanguage: "en",
+		},
+		{
+			name:             "Explicit language",
+			input:            "Programas nuevos",
+			language:         "es",
+			expectedCategory: "Programación",
+			expectedLanguage: "es",
+		},
+		{
+			name:             "Detected language",
+			input:            "Los programadores de la ciudad",
+			language:         AutoLanguage,
+			expectedCategory: "Programación",
+			expectedLanguage: "es",
+		},
+		{
+			name:             "Category restricted to another language",
+			input:            "Programación en inglés",
+			language:         "en",
+			expectedCategory: "Unknown",
+			expectedLanguage: "en",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := classifier.ClassifyWith(tt.input, ClassifyOptions{Language: tt.language})
+			if result.Category != tt.expectedCategory {
+				t.Errorf("ClassifyWith() category = %v, want %v", result.Category, tt.expectedCategory)
+			}
+			if result.Language != tt.expectedLanguage {
+				t.Errorf("ClassifyWith() language = %v, want %v", result.Language, tt.expectedLanguage)
+			}
+		})
+	}
+
+	t.Run("Detect language", func(t *testing.T) {
+		inputs := map[string]string{
+			"The cat is on the mat":                 "en",
+			"Der Hund und die Katze sind für mich":  "de",
+			"Saya akan pergi ke pasar dengan teman": "id",
+			"xyz":                                   "en",
+		}
+		for input, expected := range inputs {
+			if language := DetectLanguage(input, DefaultLanguage); language != expected {
+				t.Errorf("DetectLanguage(%q) = %v, want %v", input, language, expected)
+			}
+		}
+	})
+}
+
+func TestClassifierWeights(t *testing.T) {
+	low := 0.5
+	categories := []Category{
+		{Name: "Phrase", Phrases: Terms("machine learning")},
+		{Name: "Keyword", Keywords: Terms("machine", "learning")},
+	}
+
+	t.Run("Default profile", func(t *testing.T) {
+		classifier := &RuleClassifier{}
+		classifier.Init(categories)
+		ranked := classifier.Rank("machine learning", ClassifyOptions{})
+		if len(ranked) != 2 || ranked[0].Confidence != ranked[1].Confidence {
+			t.Errorf("Rank() = %+v, want a tie", ranked)
+		}
+	})
+
+	t.Run("Category override", func(t *testing.T) {
+		overridden := append([]Category{}, categories...)
+		overridden[0].Weights = &Weights{Phrase: &low}
+
+		classifier := &RuleClassifier{}
+		classifier.Init(overridden)
+		if result := classifier.Classify("machine learning"); result.Category != "Keyword" {
+			t.Errorf("Classify() category = %v, want Keyword", result.Category)
+		}
+	})
+
+	t.Run("Global profile", func(t *testing.T) {
+		classifier := &RuleClassifier{}
+		classifier.Init(categories, WithWeights(WeightProfile{Keyword: 1.0, Phrase: 3.0, Context: 1.5}))
+		if result := classifier.Classify("machine learning"); result.Category != "Phrase" {
+			t.Errorf("Classify() category = %v, want Phrase", result.Category)
+		}
+	})
+
+	t.Run("Term weights", func(t *testing.T) {
+		light, heavy := 0.3, 1.5
+		classifier := &RuleClassifier{}
+		classifier.Init([]Category{
+			{Name: "Generic", Keywords: []Term{{Text: "system", Weight: &light}, {Text: "network", Weight: &light}}},
+			{Name: "Neural", Keywords: []Term{{Text: "neural", Weight: &heavy}}},
+		})
+		if result := classifier.Classify("neural network system"); result.Category != "Neural" {
+			t.Errorf("Classify() category = %v, want Neural", result.Category)
+		}
+	})
+
+	t.Run("Negative weight", func(t *testing.T) {
+		negative := -1.0
+		category := Category{Name: "Bad", Weights: &Weights{Keyword: &negative}}
+		if err := category.Validate(); err == nil {
+			t.Error("Validate() error = nil, want error")
+		}
+	})
+
+	t.Run("Fuzzy weight above 1", func(t *testing.T) {
+		high := 1.5
+		category := Category{Name: "Bad", Weights: &Weights{Fuzzy: &high}}
+		if err := category.Validate(); err == nil {
+			t.Error("Validate() error = nil, want error")
+		}
+	})
+}
+
+func TestClassifierNegation(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("computer", "software"), Excluders: Terms("recipe")},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		name             string
+		input            string
+		expectedCategory string
+		expectedMatches  []string
+	}{
+		{
+			name:             "Negated match is dropped",
+			input:            "This is not about computers",
+			expectedCategory: "Unknown",
+			expectedMatches:  []string{"computer (negated)"},
+		},
+		{
+			name:             "Negation outside the window",
+			input:            "Never mind the weather, this new computer is fast",
+			expectedCategory: "Technology",
+			expectedMatches:  []string{"computer"},
+		},
+		{
+			name:             "Negation recorded in matches",
+			input:            "Software without a computer",
+			expectedCategory: "Technology",
+			expectedMatches:  []string{"computer (negated)", "software"},
+		},
+		{
+			name:             "Negated excluder does not exclude",
+			input:            "Software, not a recipe",
+			expectedCategory: "Technology",
+			expectedMatches:  []string{"software"},
+		},
+		{
+			name:             "Negation stops at a sentence boundary",
+			input:            "I do not like it. Software is great.",
+			expectedCategory: "Technology",
+			expectedMatches:  []string{"software"},
+		},
+		{
+			name:             "Negation stops at a clause boundary",
+			input:            "Not today; computers later",
+			expectedCategory: "Technology",
+			expectedMatches:  []string{"computer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := classifier.Classify(tt.input)
+			if result.Category != tt.expectedCategory {
+				t.Errorf("Classify() category = %v, want %v", result.Category, tt.expectedCategory)
+			}
+			if tt.expectedMatches != nil && fmt.Sprint(result.Matches) != fmt.Sprint(tt.expectedMatches) {
+				t.Errorf("Classify() matches = %v, want %v", result.Matches, tt.expectedMatches)
+			}
+		})
+	}
+
+	t.Run("Discounted negation", func(t *testing.T) {
+		classifier := &RuleClassifier{}
+		classifier.Init(categories, WithNegation(NegationConfig{Window: 3, Factor: 0.5}))
+		result := classifier.Classify("not computer")
+		if result.Category != "Technology" || result.Score != 0.5 {
+			t.Errorf("Classify() = %v %v, want Technology 0.5", result.Category, result.Score)
+		}
+	})
+}
+
+func TestClassifierFuzzy(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("software", "algorithm"), Fuzzy: 1},
+		{Name: "Food", Keywords: []Term{{Text: "spaghetti", Fuzzy: 2}, {Text: "pasta"}}},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		name             string
+		input            string
+		expectedCategory string
+		expectedMatches  []string
+	}{
+		{
+			name:             "Category fuzzy distance",
+			input:            "New sofware release",
+			expectedCategory: "Technology",
+			expectedMatches:  []string{"software (fuzzy: sofware)"},
+		},
+		{
+			name:             "Term fuzzy distance",
+			input:            "Spagetty tonight",
+			expectedCategory: "Food",
+			expectedMatches:  []string{"spaghetti (fuzzy: Spagetty)"},
+		},
+		{
+			name:             "Too far",
+			input:            "Sftwre release",
+			expectedCategory: "Unknown",
+		},
+		{
+			name:             "Fuzzy not enabled",
+			input:            "Pastas tonight",
+			expectedCategory: "Food",
+			expectedMatches:  []string{"pasta"},
+		},
+		{
+			name:             "Not enabled and misspelled",
+			input:            "Pazta tonight",
+			expectedCategory: "Unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := classifier.Classify(tt.input)
+			if result.Category != tt.expectedCategory {
+				t.Errorf("Classify() category = %v, want %v", result.Category, tt.expectedCategory)
+			}
+			if tt.expectedMatches != nil && fmt.Sprint(result.Matches) != fmt.Sprint(tt.expectedMatches) {
+				t.Errorf("Classify() matches = %v, want %v", result.Matches, tt.expectedMatches)
+			}
+		})
+	}
+
+	t.Run("Fuzzy scores lower than exact", func(t *testing.T) {
+		exact := classifier.Classify("software release")
+		fuzzy := classifier.Classify("sofware release")
+		if fuzzy.Confidence >= exact.Confidence {
+			t.Errorf("fuzzy confidence %v, want less than exact %v", fuzzy.Confidence, exact.Confidence)
+		}
+	})
+
+	t.Run("Fuzzy where it cannot apply", func(t *testing.T) {
+		for _, category := range []Category{
+			{Name: "Bad", Excluders: []Term{{Text: "recipe", Fuzzy: 1}}},
+			{Name: "Bad", Phrases: []Term{{Text: "machine learning", Fuzzy: 1}}},
+			{Name: "Bad", Keywords: []Term{{Text: "crypt", Match: MatchSubstring, Fuzzy: 1}}},
+			{Name: "Bad", Keywords: []Term{{Text: "cat", Fuzzy: 1}}},
+		} {
+			if err := category.Validate(); err == nil {
+				t.Errorf("Validate(%+v) error = nil, want error", category)
+			}
+		}
+	})
+}
+
+func TestClassifierPatterns(t *testing.T) {
+	half := 0.5
+	categories := []Category{
+		{Name: "Security", Keywords: Terms("vulnerability"), Patterns: []Pattern{{Expr: `CVE-\d{4}-\d+`}}},
+		{Name: "Release", Patterns: []Pattern{{Expr: `\bv\d+\.\d+\.\d+\b`, Weight: &half}}},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		name             string
+		input            string
+		expectedCategory string
+		expectedMatches  []string
+	}{
+		{
+			name:             "Pattern match with matched text",
+			input:            "Patch for CVE-2021-44228 is out",
+			expectedCategory: "Security",
+			expectedMatches:  []string{`/CVE-\d{4}-\d+/: CVE-2021-44228`},
+		},
+		{
+			name:             "Weighted pattern",
+			input:            "Shipping v1.2.3 today",
+			expectedCategory: "Release",
+			expectedMatches:  []string{`/\bv\d+\.\d+\.\d+\b/: v1.2.3`},
+		},
+		{
+			name:             "Negated pattern",
+			input:            "This is not CVE-2020-1234 related",
+			expectedCategory: "Unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := classifier.Classify(tt.input)
+			if result.Category != tt.expectedCategory {
+				t.Errorf("Classify() category = %v, want %v", result.Category, tt.expectedCategory)
+			}
+			if tt.expectedMatches != nil && fmt.Sprint(result.Matches) != fmt.Sprint(tt.expectedMatches) {
+				t.Errorf("Classify() matches = %v, want %v", result.Matches, tt.expectedMatches)
+			}
+		})
+	}
+
+	t.Run("Invalid pattern", func(t *testing.T) {
+		category := Category{Name: "Bad", Patterns: []Pattern{{Expr: `CVE-(\d+`}}}
+		if err := category.Validate(); err == nil {
+			t.Error("Validate() error = nil, want error")
+		}
+	})
+
+	t.Run("Empty match", func(t *testing.T) {
+		for _, expr := range []string{``, `\d*`, `v?\d*`} {
+			category := Category{Name: "Bad", Patterns: []Pattern{{Expr: expr}}}
+			if err := category.Validate(); err == nil {
+				t.Errorf("Validate(%q) error = nil, want error", expr)
+			}
+		}
+
+		sc := &RuleClassifier{}
+		sc.Init([]Category{{Name: "Boundary", Patterns: []Pattern{{Expr: `\b`}}}})
+		if result := sc.Classify("the weather is nice today"); result.Category != "Unknown" {
+			t.Errorf("Classify() category = %v, want Unknown for zero-width matches", result.Category)
+		}
+	})
+}
+
+func TestClassifierContextProximity(t *testing.T) {
+	categories := []Category{
+		{Name: "Anywhere", Contexts: map[string]Context{"Data": {Related: []string{"Analysis"}}}},
+		{Name: "Near", Contexts: map[string]Context{"data": {Related: []string{"analysis"}, Window: 2}}},
+		{Name: "Ordered", Contexts: map[string]Context{"data": {Related: []string{"analysis"}, Window: 2, Ordered: true}}},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "Adjacent and in order",
+			input:    "data analysis",
+			expected: []string{"Anywhere", "Near", "Ordered"},
+		},
+		{
+			name:     "Adjacent but reversed",
+			input:    "analysis of data",
+			expected: []string{"Anywhere", "Near"},
+		},
+		{
+			name:     "Far apart",
+			input:    "data was collected last year and the analysis came later",
+			expected: []string{"Anywhere"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := classifier.Rank(tt.input, ClassifyOptions{})
+			categories := make([]string, 0, len(ranked))
+			for _, result := range ranked {
+				categories = append(categories, result.Category)
+			}
+			if fmt.Sprint(categories) != fmt.Sprint(tt.expected) {
+				t.Errorf("Rank() categories = %v, want %v", categories, tt.expected)
+			}
+		})
+	}
+
+	t.Run("Context JSON round-trip", func(t *testing.T) {
+		data := []byte(`{"data":["analysis"],"cloud":{"related":["aws"],"window":3,"ordered":true}}`)
+		var contexts map[string]Context
+		if err := json.Unmarshal(data, &contexts); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if contexts["cloud"].Window != 3 || !contexts["cloud"].Ordered {
+			t.Errorf("Unmarshal() = %+v", contexts)
+		}
+		out, err := json.Marshal(contexts)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(out) != `{"cloud":{"related":["aws"],"window":3,"ordered":true},"data":["analysis"]}` {
+			t.Errorf("Marshal() = %s", out)
+		}
+	})
+}
+
+func TestClassifierTaxonomy(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("computer", "software")},
+		{Name: "Security", Parent: "Technology", Keywords: Terms("vulnerability", "malware")},
+		{Name: "Cryptography", Parent: "Security", Keywords: Terms("cipher", "encryption")},
+		{Name: "Food", Keywords: Terms("recipe")},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		input    string
+		depth    int
+		category string
+		path     []string
+	}{
+		{input: "A new cipher", category: "Cryptography", path: []string{"Technology", "Security", "Cryptography"}},
+		{input: "Malware on my computer", category: "Security", path: []string{"Technology", "Security"}},
+		{input: "Computer software", category: "Technology", path: []string{"Technology"}},
+		{input: "A new cipher", depth: 1, category: "Technology", path: []string{"Technology"}},
+		{input: "A new cipher", depth: 2, category: "Security", path: []string{"Technology", "Security"}},
+		{input: "A recipe", depth: 2, category: "Food", path: []string{"Food"}},
+	}
+
+	for _, tt := range tests {
+		result := classifier.ClassifyWith(tt.input, ClassifyOptions{Depth: tt.depth})
+		if result.Category != tt.category || fmt.Sprint(result.Path) != fmt.Sprint(tt.path) {
+			t.Errorf("ClassifyWith(%q, depth %d) = %v %v, want %v %v", tt.input, tt.depth, result.Category, result.Path, tt.category, tt.path)
+		}
+	}
+
+	t.Run("Rolled up ranking", func(t *testing.T) {
+		ranked := classifier.Rank("Malware and a cipher", ClassifyOptions{Depth: 1})
+		if len(ranked) != 1 || ranked[0].Category != "Technology" {
+			t.Errorf("Rank() = %+v, want Technology only", ranked)
+		}
+	})
+
+	t.Run("Inherited excluders", func(t *testing.T) {
+		classifier := &RuleClassifier{}
+		classifier.Init([]Category{
+			{Name: "Technology", Keywords: Terms("computer"), Excluders: Terms("recipe")},
+			{Name: "Hardware", Parent: "Technology", Keywords: Terms("chip")},
+		})
+		if result := classifier.Classify("A chip recipe"); result.Category != "Unknown" {
+			t.Errorf("Classify() category = %v, want Unknown", result.Category)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		valid := append(categories, Category{Name: "Hashing", Parent: "Cryptography"})
+		if err := ValidateTaxonomy(valid); err != nil {
+			t.Errorf("ValidateTaxonomy() error = %v", err)
+		}
+		cycle := []Category{{Name: "A", Parent: "C"}, {Name: "B", Parent: "A"}, {Name: "C", Parent: "B"}}
+		if err := ValidateTaxonomy(cycle); err == nil {
+			t.Error("ValidateTaxonomy() with a cycle error = nil, want error")
+		}
+		if err := ValidateTaxonomy([]Category{{Name: "A", Parent: "Missing"}}); err == nil {
+			t.Error("ValidateTaxonomy() with an unknown parent error = nil, want error")
+		}
+		if err := (Category{Name: "A", Parent: "A"}).Validate(); err == nil {
+			t.Error("Validate() with itself as parent error = nil, want error")
+		}
+	})
+
+	t.Run("Tree", func(t *testing.T) {
+		tree := Tree(categories)
+		if len(tree) != 2 || tree[0].Name != "Food" || tree[1].Name != "Technology" {
+			t.Fatalf("Tree() roots = %+v", tree)
+		}
+		security := tree[1].Children
+		if len(security) != 1 || len(security[0].Children) != 1 || security[0].Children[0].Name != "Cryptography" {
+			t.Errorf("Tree() Technology children = %+v", security)
+		}
+	})
+}
+
+func TestClassifierConfidence(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("computer", "software", "code"), Phrases: Terms("machine learning")},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	phrase := classifier.Classify("machine learning")
+	if phrase.Score != 2 {
+		t.Errorf("Classify() score = %v, want 2", phrase.Score)
+	}
+
+	previous := 0.0
+	for _, input := range []string{
+		"code",
+		"computer code",
+		"computer software code",
+		"computer software code machine learning",
+	} {
+		result := classifier.Classify(input)
+		if result.Confidence <= previous || result.Confidence >= 1 {
+			t.Errorf("Classify(%q) confidence = %v, want in (%v, 1)", input, result.Confidence, previous)
+		}
+		previous = result.Confidence
+	}
+
+	short := classifier.Classify("computer")
+	long := classifier.Classify("computer news from around the world this week")
+	if long.Score != short.Score || long.Confidence >= short.Confidence {
+		t.Errorf("longer text confidence = %v, want less than %v at the same score", long.Confidence, short.Confidence)
+	}
+
+	if result := classifier.Classify("the and with"); result.Category != "Unknown" || result.Confidence != 0 {
+		t.Errorf("Classify() on stop words = %v %v, want Unknown 0", result.Category, result.Confidence)
+	}
+	if got := calibrate(2, 0, DefaultConfidenceScale); got <= 0 || got >= 1 {
+		t.Errorf("calibrate() with no words = %v, want in (0, 1)", got)
+	}
+}
+
+func TestClassifierAbstention(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("computer", "software", "code"), MinMatches: 2},
+		{Name: "Science", Keywords: Terms("research"), MinConfidence: 0.4},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		input     string
+		category  string
+		abstained bool
+		reason    string
+	}{
+		{input: "computer software", category: "Technology"},
+		{input: "computer", category: "Unknown", abstained: true, reason: "Technology: 1 of 2 required distinct matches"},
+		{input: "research", category: "Science"},
+		{input: "research on penguins in their cold natural habitat", category: "Unknown", abstained: true, reason: "Science: confidence 0.29 is below its minimum 0.40"},
+		{input: "penguins", category: "Unknown"},
+	}
+
+	for _, tt := range tests {
+		result := classifier.Classify(tt.input)
+		if result.Category != tt.category || result.Abstained != tt.abstained || result.AbstainReason != tt.reason {
+			t.Errorf("Classify(%q) = %v %v %q, want %v %v %q", tt.input, result.Category, result.Abstained, result.AbstainReason, tt.category, tt.abstained, tt.reason)
+		}
+	}
+
+	t.Run("Held back categories do not rank", func(t *testing.T) {
+		ranked := classifier.Rank("computer research", ClassifyOptions{})
+		if len(ranked) != 1 || ranked[0].Category != "Science" {
+			t.Errorf("Rank() = %+v, want Science only", ranked)
+		}
+	})
+
+	t.Run("Abstain threshold", func(t *testing.T) {
+		classifier := &RuleClassifier{}
+		classifier.Init(categories, WithAbstainThreshold(0.6))
+		result := classifier.Classify("computer software")
+		if result.Category != "Unknown" || !result.Abstained {
+			t.Errorf("Classify() = %v %v, want Unknown abstained", result.Category, result.Abstained)
+		}
+		if result := classifier.Classify("computer software code"); result.Category != "Technology" {
+			t.Errorf("Classify() category = %v, want Technology", result.Category)
+		}
+	})
+}
+
+func TestClassifierMatchSpans(t *testing.T) {
+	categories := []Category{
+		{
+			Name:     "Technology",
+			Keywords: Terms("software"),
+			Phrases:  Terms("machine learning"),
+			Patterns: []Pattern{{Expr: `v\d+`}},
+			Contexts: map[string]Context{"data": {Related: []string{"analysis"}}},
+		},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	input := "Café software v2 for machine learning and data analysis"
+	result := classifier.Classify(input)
+
+	expected := []Match{
+		{Type: MatchKeyword, Rule: "software", Text: "software", Start: 6, End: 14, RuneStart: 5, RuneEnd: 13, Score: 1},
+		{Type: MatchPhrase, Rule: "machine learning", Text: "machine learning", Start: 22, End: 38, RuneStart: 21, RuneEnd: 37, Score: 2},
+		{Type: MatchPattern, Rule: `v\d+`, Text: "v2", Start: 15, End: 17, RuneStart: 14, RuneEnd: 16, Score: 2},
+		{Type: MatchContext, Rule: "data-analysis", Text: "data analysis", Start: 43, End: 56, RuneStart: 42, RuneEnd: 55, Score: 1.5},
+	}
+	if len(result.Matches) != len(expected) {
+		t.Fatalf("Classify() matches = %v, want %v", result.Matches, expected)
+	}
+	for i, match := range result.Matches {
+		if match != expected[i] {
+			t.Errorf("Classify() match %d = %+v, want %+v", i, match, expected[i])
+		}
+		if input[match.Start:match.End] != match.Text || string([]rune(input)[match.RuneStart:match.RuneEnd]) != match.Text {
+			t.Errorf("Classify() match %d offsets do not select %q", i, match.Text)
+		}
+	}
+
+	t.Run("Legacy JSON", func(t *testing.T) {
+		var matches []Match
+		if err := json.Unmarshal([]byte(`["software",{"type":"keyword","rule":"code","text":"Code","start":0,"end":4}]`), &matches); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if len(matches) != 2 || matches[0].Rule != "software" || matches[1].Text != "Code" || matches[1].End != 4 {
+			t.Errorf("Unmarshal() = %+v", matches)
+		}
+	})
+}
+
+func TestNaiveBayes(t *testing.T) {
+	examples := []Example{
+		{Text: "The new laptop has a faster processor and more memory", Category: "Technology"},
+		{Text: "Install the software update to fix the network driver", Category: "Technology"},
+		{Text: "Our server crashed after the database migration", Category: "Technology"},
+		{Text: "Whisk the eggs and fold in the flour", Category: "Food"},
+		{Text: "Roast the vegetables with garlic and olive oil", Category: "Food"},
+		{Text: "This soup needs more salt and fresh herbs", Category: "Food"},
+	}
+
+	classifier := &NaiveBayes{}
+	classifier.Init(examples)
+
+	tests := []struct {
+		input    string
+		category string
+	}{
+		{input: "The database server needs more memory", category: "Technology"},
+		{input: "Add garlic to the soup", category: "Food"},
+		{input: "Nothing familiar here", category: "Unknown"},
+	}
+
+	for _, tt := range tests {
+		result := classifier.Classify(tt.input)
+		if result.Category != tt.category {
+			t.Errorf("Classify(%q) category = %v, want %v", tt.input, result.Category, tt.category)
+		}
+		if tt.category != "Unknown" && (result.Confidence <= 0.5 || result.Confidence > 1 || len(result.Matches) == 0) {
+			t.Errorf("Classify(%q) = %v %v, want confidence in (0.5, 1] with matches", tt.input, result.Confidence, result.Matches)
+		}
+	}
+
+	ranked := classifier.Rank("garlic on the server", ClassifyOptions{})
+	total := 0.0
+	for _, result := range ranked {
+		total += result.Confidence
+	}
+	if len(ranked) != 2 || math.Abs(total-1) > 1e-9 {
+		t.Errorf("Rank() = %+v, want 2 results summing to 1", ranked)
+	}
+
+	var backend Classifier = classifier
+	if result := backend.ClassifyWith("roast vegetables", ClassifyOptions{TopN: 1}); len(result.Labels) != 1 || result.Labels[0].Category != "Food" {
+		t.Errorf("ClassifyWith() labels = %+v, want Food", result.Labels)
+	}
+
+	empty := &NaiveBayes{}
+	empty.Init(nil)
+	if result := empty.Classify("anything"); result.Category != "Unknown" {
+		t.Errorf("untrained Classify() category = %v, want Unknown", result.Category)
+	}
+}
+
+func TestEnsemble(t *testing.T) {
+	rules := &RuleClassifier{}
+	rules.Init([]Category{
+		{Name: "Technology", Keywords: Terms("computer", "software", "server")},
+		{Name: "Food", Keywords: Terms("recipe")},
+	})
+	bayes := &NaiveBayes{}
+	bayes.Init([]Example{
+		{Text: "the server crashed after the database migration", Category: "Technology"},
+		{Text: "install the software update for the network driver", Category: "Technology"},
+		{Text: "roast the vegetables with garlic and olive oil", Category: "Food"},
+		{Text: "whisk the eggs and fold in the flour", Category: "Food"},
+	})
+
+	ensemble := &Ensemble{}
+	ensemble.Init([]Member{
+		{Name: "rules", Classifier: rules, Override: 0.5},
+		{Name: "bayes", Classifier: bayes},
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		result := ensemble.Classify("computer software recipe")
+		if result.Category != "Technology" || len(result.Votes) != 2 || !result.Votes[0].Override {
+			t.Errorf("Classify() = %v %+v, want Technology overridden by rules", result.Category, result.Votes)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		result := ensemble.Classify("garlic and olive oil")
+		if result.Category != "Food" {
+			t.Errorf("Classify() category = %v, want Food", result.Category)
+		}
+		if votes := result.Votes; votes[0].Category != "Unknown" || votes[1].Category != "Food" || votes[1].Confidence != result.Confidence {
+			t.Errorf("Classify() votes = %+v", votes)
+		}
+	})
+
+	t.Run("Weighted vote", func(t *testing.T) {
+		result := ensemble.Classify("a recipe for the database")
+		votes := result.Votes
+		if votes[0].Override || votes[1].Override {
+			t.Fatalf("Classify() votes = %+v, want no override", votes)
+		}
+		want := (votes[0].Confidence*votes[0].Weight + votes[1].Confidence*votes[1].Weight) / 2
+		if math.Abs(result.Confidence-want) > 1e-9 {
+			t.Errorf("Classify() confidence = %v, want the weighted mean %v", result.Confidence, want)
+		}
+	})
+
+	t.Run("Nothing matches", func(t *testing.T) {
+		if result := ensemble.Classify("penguins"); result.Category != "Unknown" || len(result.Votes) != 2 {
+			t.Errorf("Classify() = %v %+v, want Unknown with votes", result.Category, result.Votes)
+		}
+	})
+}
+
+func TestClassifierLexicons(t *testing.T) {
+	double := 2.0
+	categories := []Category{
+		{Name: "Cloud", Keywords: []Term{{Text: "@cloud_vendors", Weight: &double}, {Text: "cloud"}}},
+		{Name: "Retail", Keywords: Terms("shopping"), Excluders: Terms("@cloud_vendors")},
+	}
+	lexicons := []Lexicon{{Name: "cloud_vendors", Terms: []string{"aws", "azure", "google cloud"}}}
+
+	sc := &RuleClassifier{}
+	sc.Init(categories, WithLexicons(lexicons))
+	result := sc.Classify("shopping for google cloud credits")
+	if result.Category != "Cloud" || len(result.Matches) == 0 || result.Matches[0].Rule != "google cloud" {
+		t.Errorf("Classify() = %v %v, want Cloud matching the lexicon phrase", result.Category, result.Matches)
+	}
+
+	lexicons[0].Terms = []string{"oracle"}
+	sc.Init(categories, WithLexicons(lexicons))
+	if result := sc.Classify("moving to oracle"); result.Category != "Cloud" {
+		t.Errorf("Classify() = %v, want Cloud after the lexicon changed", result.Category)
+	}
+	if result := sc.Classify("moving to azure"); result.Category != "Unknown" {
+		t.Errorf("Classify() = %v, want Unknown for a term removed from the lexicon", result.Category)
+	}
+
+	if _, err := ExpandLexicons(categories, nil); err == nil {
+		t.Error("ExpandLexicons() with a missing lexicon succeeded")
+	}
+	if users := LexiconUsers(categories, "cloud_vendors"); len(users) != 2 {
+		t.Errorf("LexiconUsers() = %v, want both categories", users)
+	}
+}
@@ -0,0 +1,162 @@
+package proc
+
+import (
+	"sort"
+	"strings"
+)
+
+type ruleKind int
+
+const (
+	ruleExcluder ruleKind = iota
+	ruleKeyword
+	rulePhrase
+	rulePattern
+	ruleContext
+	ruleRelated
+	ruleTerm
+	ruleExpression
+)
+
+type rule struct {
+	category    int
+	kind        ruleKind
+	text        string
+	weight      float64
+	fuzzyWeight float64
+	context     int
+	window      int
+	ordered     bool
+	expression  *exprNode
+}
+
+// ruleSet holds the rules of every category that applies to one language,
+// normalized with that language's pipeline and indexed in a single matcher.
+type ruleSet struct {
+	language string
+	pipeline Pipeline
+	rules    []rule
+	matcher  *matcher
+	fuzzy    *bkTree
+	patterns []compiledPattern
+
+	// expressions maps a category to its ruleExpression rule.
+	expressions map[int]int
+
+	negations      map[string]bool
+	negationWindow int
+	negationFactor float64
+}
+
+func (sc *RuleClassifier) compileRuleSet(language Language, pipeline Pipeline) *ruleSet {
+	rs := &ruleSet{
+		language: language.Code,
+		pipeline: pipeline,
+		rules:    make([]rule, 0),
+		matcher:  newMatcher(),
+		fuzzy:    &bkTree{},
+
+		expressions: make(map[int]int),
+
+		negations:      sc.negationCues(language, pipeline),
+		negationWindow: sc.negation.Window,
+		negationFactor: sc.negation.Factor,
+	}
+
+	for i, category := range sc.categories {
+		if !category.AppliesTo(language.Code) {
+			continue
+		}
+		weights := category.Weights.Resolve(sc.weights)
+
+		for _, excluder := range category.Excluders {
+			rs.addRule(rule{category: i, kind: ruleExcluder, text: excluder.Text}, excluder.Match)
+		}
+		for _, keyword := range category.Keywords {
+			weight := weights.Keyword * keyword.Factor()
+			id := rs.addRule(rule{
+				category: i, kind: ruleKeyword, text: keyword.Text, weight: weight, fuzzyWeight: weight * weights.Fuzzy,
+			}, keyword.Match)
+			rs.addFuzzy(id, keyword, category.Fuzzy)
+		}
+		for _, phrase := range category.Phrases {
+			weight := weights.Phrase * phrase.Factor()
+			id := rs.addRule(rule{
+				category: i, kind: rulePhrase, text: phrase.Text, weight: weight, fuzzyWeight: weight * weights.Fuzzy,
+			}, phrase.Match)
+			rs.addFuzzy(id, phrase, category.Fuzzy)
+		}
+		for _, pattern := range category.Patterns {
+			re, err := pattern.Compile()
+			if err != nil {
+				continue
+			}
+			id := rs.addRule(rule{category: i, kind: rulePattern, text: pattern.Expr, weight: weights.Pattern * pattern.Factor()}, matchNone)
+			rs.patterns = append(rs.patterns, compiledPattern{re: re, rule: id})
+		}
+
+		if expression, err := parseExpression(category.Expression); category.Expression != "" && err == nil {
+			terms := make(map[string]int)
+			bound := expression.bind(func(text string) int {
+				if id, ok := terms[text]; ok {
+					return id
+				}
+				terms[text] = rs.addRule(rule{category: i, kind: ruleTerm, text: text}, MatchToken)
+				return terms[text]
+			})
+			rs.expressions[i] = rs.addRule(rule{
+				category: i, kind: ruleExpression, text: category.Expression, weight: weights.Expression, expression: bound,
+			}, matchNone)
+		}
+
+		contexts := make([]string, 0, len(category.Contexts))
+		for context := range category.Contexts {
+			contexts = append(contexts, context)
+		}
+		sort.Strings(contexts)
+		for _, context := range contexts {
+			id := rs.addRule(rule{category: i, kind: ruleContext, text: context}, MatchToken)
+			rules := category.Contexts[context]
+			for _, related := range rules.Related {
+				rs.addRule(rule{
+					category: i, kind: ruleRelated, text: related, weight: weights.Context,
+					context: id, window: rules.Window, ordered: rules.Ordered,
+				}, MatchToken)
+			}
+		}
+	}
+
+	rs.matcher.build()
+	return rs
+}
+
+func (rs *ruleSet) addRule(r rule, mode MatchMode) int {
+	id := len(rs.rules)
+	rs.rules = append(rs.rules, r)
+	if mode == matchNone {
+		return id
+	}
+	if mode == MatchSubstring {
+		rs.matcher.addSubstring(strings.ToLower(r.text), id)
+	} else if seq := rs.pipeline.terms(r.text); len(seq) > 0 {
+		rs.matcher.addSequence(seq, id)
+	}
+	return id
+}
+
+// addFuzzy indexes a single-word term for typo-tolerant matching when the
+// term, or else its category, allows an edit distance.
+func (rs *ruleSet) addFuzzy(id int, term Term, categoryDistance int) {
+	distance := term.Fuzzy
+	if distance == 0 {
+		distance = categoryDistance
+	}
+	if distance <= 0 || term.Match != MatchToken {
+		return
+	}
+	seq := rs.pipeline.terms(term.Text)
+	if len(seq) != 1 || len([]rune(seq[0])) < MinFuzzyLength {
+		return
+	}
+	rs.fuzzy.add(seq[0], fuzzyRule{id: id, maxDistance: distance})
+}
@@ -0,0 +1,23 @@
+package proc
+
+import "math"
+
+// DefaultConfidenceScale is the raw score, per square root of the number of
+// words in the text, that maps to a confidence of 0.5.
+const DefaultConfidenceScale = 1.0
+
+// calibrate turns a raw score into a confidence in [0,1):
+//
+//	confidence = score / (score + scale·√words)
+//
+// Confidence grows with the score and saturates towards 1, so a category can
+// never exceed 1 however many rules it matches. Longer texts need a higher
+// score for the same confidence, but only with the square root of their
+// length, so a single strong match in a long text is not drowned out. Texts
+// made only of stop words count as one word.
+func calibrate(score float64, words int, scale float64) float64 {
+	if score <= 0 {
+		return 0
+	}
+	return score / (score + scale*math.Sqrt(float64(max(words, 1))))
+}
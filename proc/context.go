@@ -0,0 +1,61 @@
+package proc
+
+import "encoding/json"
+
+// Context lists words that are evidence for a category when they appear
+// together with the context word. Window limits how many tokens apart the
+// two may be (0 means anywhere in the text) and Ordered requires the context
+// word to come first. In JSON it is either a plain list of related words or
+// an object carrying those options.
+type Context struct {
+	Related []string `json:"related"`
+	Window  int      `json:"window,omitempty"`
+	Ordered bool     `json:"ordered,omitempty"`
+}
+
+func (c Context) MarshalJSON() ([]byte, error) {
+	if c.Window == 0 && !c.Ordered {
+		return json.Marshal(c.Related)
+	}
+	type context Context
+	return json.Marshal(context(c))
+}
+
+func (c *Context) UnmarshalJSON(data []byte) error {
+	var related []string
+	if err := json.Unmarshal(data, &related); err == nil {
+		*c = Context{Related: related}
+		return nil
+	}
+	type context Context
+	var v context
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = Context(v)
+	return nil
+}
+
+// contextPair looks for an occurrence of the context word and of the related
+// word that satisfies the rule's window and order, preferring a pair that is
+// not negated.
+func (rs *ruleSet) contextPair(doc document, r rule, context, related *ruleHits) (c, h hit, found, negated bool) {
+	for _, ch := range context.hits {
+		for _, rh := range related.hits {
+			distance := rh.start - ch.start
+			if r.ordered && distance <= 0 {
+				continue
+			}
+			if r.window > 0 && (distance > r.window || -distance > r.window) {
+				continue
+			}
+			if !rs.negated(doc.tokens, ch) && !rs.negated(doc.tokens, rh) {
+				return ch, rh, true, false
+			}
+			if !found {
+				c, h, found, negated = ch, rh, true, true
+			}
+		}
+	}
+	return c, h, found, negated
+}
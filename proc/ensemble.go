@@ -0,0 +1,153 @@
+package proc
+
+import "math"
+
+var _ Classifier = (*Ensemble)(nil)
+
+// Member is one backend of an Ensemble. Weight is its share of the vote and
+// defaults to 1. When Override is set, a best category the member reports
+// with at least that confidence wins outright, without a vote.
+type Member struct {
+	Name       string
+	Classifier Classifier
+	Weight     float64
+	Override   float64
+}
+
+// Vote is what one member of an ensemble contributed to a result: its own
+// best category and the confidence and score it gave the final one.
+type Vote struct {
+	Backend    string  `json:"backend"`
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+	Score      float64 `json:"score"`
+	Weight     float64 `json:"weight"`
+	Override   bool    `json:"override,omitempty"`
+}
+
+// Ensemble combines several backends. Members are consulted in order for
+// overrides; otherwise each category's confidence is the weighted mean of
+// the confidences the members gave it. Members that match nothing stay out
+// of the mean, so a later member serves as the fallback for an earlier one.
+// The merged Score is the weighted sum of those confidences.
+type Ensemble struct {
+	members []Member
+}
+
+func (e *Ensemble) Init(members []Member) {
+	e.members = make([]Member, len(members))
+	for i, member := range members {
+		if member.Weight <= 0 {
+			member.Weight = 1
+		}
+		e.members[i] = member
+	}
+}
+
+func (e *Ensemble) Classify(sentence string) ClassificationResult {
+	return e.ClassifyWith(sentence, ClassifyOptions{})
+}
+
+func (e *Ensemble) ClassifyWith(sentence string, opts ClassifyOptions) ClassificationResult {
+	ranked, votes := e.rank(sentence, opts)
+	if len(ranked) == 0 {
+		return ClassificationResult{
+			Category:   "Unknown",
+			Confidence: 0.0,
+			Matches:    nil,
+			Votes:      votes,
+		}
+	}
+
+	result := ranked[0]
+	result.Votes = votes
+	if opts.MultiLabel() {
+		result.Labels = make([]Label, 0)
+		for _, label := range filterRanked(ranked, opts) {
+			result.Labels = append(result.Labels, Label{
+				Category:   label.Category,
+				Path:       label.Path,
+				Confidence: label.Confidence,
+				Score:      label.Score,
+				Matches:    label.Matches,
+			})
+		}
+	}
+	return result
+}
+
+func (e *Ensemble) Rank(sentence string, opts ClassifyOptions) []ClassificationResult {
+	ranked, _ := e.rank(sentence, opts)
+	return filterRanked(ranked, opts)
+}
+
+// rank returns the merged ranking along with each member's vote for its
+// first category.
+func (e *Ensemble) rank(sentence string, opts ClassifyOptions) ([]ClassificationResult, []Vote) {
+	memberOpts := ClassifyOptions{Language: opts.Language, Depth: opts.Depth}
+	rankings := make([][]ClassificationResult, len(e.members))
+	for i, member := range e.members {
+		rankings[i] = member.Classifier.Rank(sentence, memberOpts)
+	}
+
+	for i, member := range e.members {
+		if ranked := rankings[i]; member.Override > 0 && len(ranked) > 0 && ranked[0].Confidence >= member.Override {
+			votes := e.votes(rankings, ranked[0].Category)
+			votes[i].Override = true
+			return []ClassificationResult{ranked[0]}, votes
+		}
+	}
+
+	merged := make(map[string]*ClassificationResult)
+	order := make([]string, 0)
+	total := 0.0
+	for i, member := range e.members {
+		if len(rankings[i]) == 0 {
+			continue
+		}
+		total += member.Weight
+		for _, result := range rankings[i] {
+			m, ok := merged[result.Category]
+			if !ok {
+				m = &ClassificationResult{Category: result.Category, Language: result.Language, Matches: make([]Match, 0)}
+				merged[result.Category] = m
+				order = append(order, result.Category)
+			}
+			if len(result.Path) > len(m.Path) {
+				m.Path = result.Path
+			}
+			m.Score += member.Weight * result.Confidence
+			m.Matches = append(m.Matches, result.Matches...)
+		}
+	}
+
+	ranked := make([]ClassificationResult, 0, len(order))
+	for _, category := range order {
+		result := *merged[category]
+		result.Confidence = math.Min(result.Score/total, 1)
+		ranked = append(ranked, result)
+	}
+	sortRanked(ranked)
+
+	if len(ranked) == 0 {
+		return ranked, e.votes(rankings, "")
+	}
+	return ranked, e.votes(rankings, ranked[0].Category)
+}
+
+func (e *Ensemble) votes(rankings [][]ClassificationResult, category string) []Vote {
+	votes := make([]Vote, len(e.members))
+	for i, member := range e.members {
+		votes[i] = Vote{Backend: member.Name, Category: "Unknown", Weight: member.Weight}
+		if len(rankings[i]) > 0 {
+			votes[i].Category = rankings[i][0].Category
+		}
+		for _, result := range rankings[i] {
+			if result.Category == category {
+				votes[i].Confidence = result.Confidence
+				votes[i].Score = result.Score
+			}
+		}
+	}
+	return votes
+}
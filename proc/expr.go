@@ -0,0 +1,308 @@
+package proc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions combine words and quoted phrases with boolean operators:
+//
+//	(cloud AND (aws OR azure)) AND NOT weather
+//	"machine learning" NEAR/5 model
+//
+// Operators are upper case. NOT binds tightest, then NEAR/n, AND and OR.
+// a NEAR/n b holds when an occurrence of a starts within n words of one of b.
+
+type ParseError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+type exprOp int
+
+const (
+	exprTerm exprOp = iota
+	exprAnd
+	exprOr
+	exprNot
+	exprNear
+)
+
+type exprNode struct {
+	op       exprOp
+	text     string
+	distance int
+	children []*exprNode
+	rule     int
+}
+
+type exprTokenKind int
+
+const (
+	tokWord exprTokenKind = iota
+	tokPhrase
+	tokAnd
+	tokOr
+	tokNot
+	tokNear
+	tokLParen
+	tokRParen
+	tokEOF
+)
+
+type exprToken struct {
+	kind     exprTokenKind
+	text     string
+	distance int
+	line     int
+	column   int
+}
+
+func lexExpression(input string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	runes := []rune(input)
+	line, column := 1, 1
+
+	advance := func(i int) {
+		if runes[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		startLine, startColumn := line, column
+
+		switch {
+		case unicode.IsSpace(r):
+			advance(i)
+			i++
+		case r == '(' || r == ')':
+			kind := tokLParen
+			if r == ')' {
+				kind = tokRParen
+			}
+			tokens = append(tokens, exprToken{kind: kind, text: string(r), line: startLine, column: startColumn})
+			advance(i)
+			i++
+		case r == '"':
+			advance(i)
+			i++
+			start := i
+			for i < len(runes) && runes[i] != '"' {
+				advance(i)
+				i++
+			}
+			if i == len(runes) {
+				return nil, &ParseError{Line: startLine, Column: startColumn, Message: "unterminated phrase"}
+			}
+			tokens = append(tokens, exprToken{kind: tokPhrase, text: string(runes[start:i]), line: startLine, column: startColumn})
+			advance(i)
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()"`, runes[i]) {
+				advance(i)
+				i++
+			}
+			word := string(runes[start:i])
+			token := exprToken{kind: tokWord, text: word, line: startLine, column: startColumn}
+			switch {
+			case word == "AND":
+				token.kind = tokAnd
+			case word == "OR":
+				token.kind = tokOr
+			case word == "NOT":
+				token.kind = tokNot
+			case word == "NEAR" || strings.HasPrefix(word, "NEAR/"):
+				distance, err := strconv.Atoi(strings.TrimPrefix(word, "NEAR/"))
+				if err != nil || distance <= 0 {
+					return nil, &ParseError{Line: startLine, Column: startColumn, Message: "NEAR needs a positive distance, as in NEAR/5"}
+				}
+				token.kind = tokNear
+				token.distance = distance
+			}
+			tokens = append(tokens, token)
+		}
+	}
+
+	return append(tokens, exprToken{kind: tokEOF, line: line, column: column}), nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func parseExpression(input string) (*exprNode, error) {
+	tokens, err := lexExpression(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokEOF {
+		return nil, p.errorf(next, "expected AND, OR or NEAR/n, found %q", next.text)
+	}
+	if holds, _, _ := node.eval(nil); holds {
+		return nil, p.errorf(tokens[0], "expression holds without any of its terms, so it matches every text")
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	token := p.tokens[p.pos]
+	if token.kind != tokEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *exprParser) errorf(token exprToken, format string, args ...any) error {
+	return &ParseError{Line: token.line, Column: token.column, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) parseOr() (*exprNode, error) {
+	return p.parseBinary(tokOr, exprOr, p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (*exprNode, error) {
+	return p.parseBinary(tokAnd, exprAnd, p.parseNear)
+}
+
+func (p *exprParser) parseBinary(kind exprTokenKind, op exprOp, operand func() (*exprNode, error)) (*exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == kind {
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{op: op, children: []*exprNode{left, right}}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNear() (*exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokNear {
+		near := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{op: exprNear, distance: near.distance, children: []*exprNode{left, right}}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (*exprNode, error) {
+	token := p.next()
+	switch token.kind {
+	case tokNot:
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{op: exprNot, children: []*exprNode{operand}}, nil
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		switch closing := p.next(); closing.kind {
+		case tokRParen:
+		case tokEOF:
+			return nil, p.errorf(token, "unclosed parenthesis")
+		default:
+			return nil, p.errorf(closing, "expected AND, OR, NEAR/n or ), found %q", closing.text)
+		}
+		return node, nil
+	case tokWord, tokPhrase:
+		if strings.TrimSpace(token.text) == "" {
+			return nil, p.errorf(token, "empty phrase")
+		}
+		return &exprNode{op: exprTerm, text: token.text}, nil
+	case tokEOF:
+		return nil, p.errorf(token, "unexpected end of expression")
+	}
+	return nil, p.errorf(token, "unexpected %q", token.text)
+}
+
+// bind copies the tree, assigning each term the rule id returned by ruleFor.
+func (n *exprNode) bind(ruleFor func(text string) int) *exprNode {
+	bound := &exprNode{op: n.op, text: n.text, distance: n.distance, rule: n.rule}
+	if n.op == exprTerm {
+		bound.rule = ruleFor(n.text)
+	}
+	for _, child := range n.children {
+		bound.children = append(bound.children, child.bind(ruleFor))
+	}
+	return bound
+}
+
+// eval reports whether the expression holds for the matched rules, along
+// with the hits of the terms that made it hold. negated is set when it only
+// holds through terms whose every hit is negated; under NOT such a term
+// counts as absent.
+func (n *exprNode) eval(matched map[int]*ruleHits) (holds bool, hits []hit, negated bool) {
+	switch n.op {
+	case exprTerm:
+		rh, ok := matched[n.rule]
+		if !ok || rh.fuzzy {
+			return false, nil, false
+		}
+		return true, rh.hits, rh.negated
+	case exprNot:
+		ok, _, negated := n.children[0].eval(matched)
+		return !ok || negated, nil, false
+	case exprAnd, exprOr, exprNear:
+		left, leftHits, leftNegated := n.children[0].eval(matched)
+		right, rightHits, rightNegated := n.children[1].eval(matched)
+		switch {
+		case n.op == exprOr && left && right:
+			return true, append(append([]hit{}, leftHits...), rightHits...), leftNegated && rightNegated
+		case n.op == exprOr && left:
+			return true, leftHits, leftNegated
+		case n.op == exprOr && right:
+			return true, rightHits, rightNegated
+		case !left || !right:
+			return false, nil, false
+		case n.op == exprAnd:
+			return true, append(append([]hit{}, leftHits...), rightHits...), leftNegated || rightNegated
+		}
+		hits := make([]hit, 0)
+		for _, a := range leftHits {
+			for _, b := range rightHits {
+				if a.start-b.start <= n.distance && b.start-a.start <= n.distance {
+					hits = append(hits, a, b)
+				}
+			}
+		}
+		return len(hits) > 0, hits, leftNegated || rightNegated
+	}
+	return false, nil, false
+}
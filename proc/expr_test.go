@@ -0,0 +1,93 @@
+package proc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{input: "", line: 1, column: 1},
+		{input: "cloud AND", line: 1, column: 10},
+		{input: "(cloud OR aws", line: 1, column: 1},
+		{input: "cloud aws", line: 1, column: 7},
+		{input: "cloud AND\n  \"open phrase", line: 2, column: 3},
+		{input: "cloud NEAR aws", line: 1, column: 7},
+		{input: "cloud AND (aws OR)", line: 1, column: 18},
+	}
+
+	for _, tt := range tests {
+		_, err := parseExpression(tt.input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("parseExpression(%q) error = %v, want a ParseError", tt.input, err)
+			continue
+		}
+		if parseErr.Line != tt.line || parseErr.Column != tt.column {
+			t.Errorf("parseExpression(%q) error at %d:%d, want %d:%d (%v)", tt.input, parseErr.Line, parseErr.Column, tt.line, tt.column, err)
+		}
+	}
+}
+
+func TestClassifierExpression(t *testing.T) {
+	categories := []Category{
+		{Name: "Cloud", Expression: `(cloud AND (aws OR azure)) AND NOT weather`},
+		{Name: "ML", Expression: `"machine learning" NEAR/3 model`},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		input            string
+		expectedCategory string
+	}{
+		{input: "Moving our cloud workloads to AWS", expectedCategory: "Cloud"},
+		{input: "Azure cloud pricing", expectedCategory: "Cloud"},
+		{input: "Cloud weather over the AWS office", expectedCategory: "Unknown"},
+		{input: "Cloud storage explained", expectedCategory: "Unknown"},
+		{input: "A machine learning model", expectedCategory: "ML"},
+		{input: "Machine learning is fun, and after a long day we built a model", expectedCategory: "Unknown"},
+		{input: "This is not about cloud or aws", expectedCategory: "Unknown"},
+		{input: "Cloud on AWS, not weather", expectedCategory: "Cloud"},
+	}
+
+	for _, tt := range tests {
+		result := classifier.Classify(tt.input)
+		if result.Category != tt.expectedCategory {
+			t.Errorf("Classify(%q) category = %v, want %v", tt.input, result.Category, tt.expectedCategory)
+		}
+	}
+
+	t.Run("Negation only", func(t *testing.T) {
+		for _, expression := range []string{"NOT weather", "cloud OR NOT weather"} {
+			category := Category{Name: "Clear", Expression: expression}
+			if err := category.Validate(); err == nil {
+				t.Errorf("Validate(%q) error = nil, want error", expression)
+			}
+		}
+		classifier := &RuleClassifier{}
+		classifier.Init([]Category{{Name: "Clear", Expression: "NOT weather"}})
+		if result := classifier.Classify("hello"); result.Category != "Unknown" {
+			t.Errorf("Classify() category = %v, want Unknown", result.Category)
+		}
+	})
+
+	t.Run("Phrase span", func(t *testing.T) {
+		result := classifier.Classify("A model for machine learning")
+		if len(result.Matches) != 1 || result.Matches[0].Text != "model for machine learning" {
+			t.Errorf("Classify() matches = %+v, want the span to end after the phrase", result.Matches)
+		}
+	})
+
+	t.Run("Invalid expression", func(t *testing.T) {
+		category := Category{Name: "Bad", Expression: "cloud AND (aws"}
+		if err := category.Validate(); err == nil {
+			t.Error("Validate() error = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package proc
+
+// MinFuzzyLength is the shortest rule word, in runes, that is matched
+// fuzzily. Shorter words are too easily confused with unrelated ones.
+const MinFuzzyLength = 4
+
+// MaxFuzzyDistance bounds the edit distance a category or term may allow.
+const MaxFuzzyDistance = 3
+
+// bkTree indexes the normalized rule words that allow fuzzy matching, so the
+// words within a given edit distance of an input token are found without
+// comparing against the whole vocabulary.
+type bkTree struct {
+	root        *bkNode
+	maxDistance int
+}
+
+type bkNode struct {
+	word     string
+	rules    []fuzzyRule
+	children map[int]*bkNode
+}
+
+type fuzzyRule struct {
+	id          int
+	maxDistance int
+}
+
+func (t *bkTree) add(word string, rule fuzzyRule) {
+	t.maxDistance = max(t.maxDistance, rule.maxDistance)
+	if t.root == nil {
+		t.root = &bkNode{word: word, rules: []fuzzyRule{rule}, children: make(map[int]*bkNode)}
+		return
+	}
+
+	node := t.root
+	for {
+		distance := levenshtein(word, node.word)
+		if distance == 0 {
+			node.rules = append(node.rules, rule)
+			return
+		}
+		child, ok := node.children[distance]
+		if !ok {
+			node.children[distance] = &bkNode{word: word, rules: []fuzzyRule{rule}, children: make(map[int]*bkNode)}
+			return
+		}
+		node = child
+	}
+}
+
+// search calls found for every rule whose word is within its allowed
+// distance of word, excluding exact matches.
+func (t *bkTree) search(word string, found func(rule fuzzyRule, distance int)) {
+	if t.root == nil {
+		return
+	}
+
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		distance := levenshtein(word, node.word)
+		if distance > 0 {
+			for _, rule := range node.rules {
+				if distance <= rule.maxDistance {
+					found(rule, distance)
+				}
+			}
+		}
+		for d := distance - t.maxDistance; d <= distance+t.maxDistance; d++ {
+			if child, ok := node.children[d]; ok {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
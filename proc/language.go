@@ -0,0 +1,189 @@
+package proc
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultLanguage = "en"
+	AutoLanguage    = "auto"
+)
+
+// Language bundles the stop words, negation cues and stemmer used to normalize text written
+// in it. Registered languages can be selected per request or detected.
+type Language struct {
+	Code      string
+	Name      string
+	StopWords []string
+	Negations []string
+	Stemmer   Normalizer
+}
+
+func (l Language) Pipeline() Pipeline {
+	stopWords := make(map[string]bool)
+	for _, word := range l.StopWords {
+		stopWords[word] = true
+	}
+
+	pipeline := Pipeline{UnicodeNormalizer{}, CaseFolder{}, StopWordFilter{Words: stopWords}}
+	if l.Stemmer != nil {
+		pipeline = append(pipeline, l.Stemmer)
+	}
+	return pipeline
+}
+
+var (
+	languagesMu sync.RWMutex
+	languages   = map[string]Language{}
+)
+
+func RegisterLanguage(language Language) {
+	languagesMu.Lock()
+	defer languagesMu.Unlock()
+	languages[language.Code] = language
+}
+
+func LookupLanguage(code string) (Language, bool) {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	language, ok := languages[code]
+	return language, ok
+}
+
+func LanguageCodes() []string {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	return sortedLanguageCodes()
+}
+
+// DetectLanguage picks the registered language whose stop words cover the
+// most words of text, falling back to fallback when nothing stands out.
+func DetectLanguage(text string, fallback string) string {
+	tokens := Pipeline{UnicodeNormalizer{}, CaseFolder{}}.Tokenize(text)
+
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+
+	best, bestCount := fallback, 0
+	for _, code := range sortedLanguageCodes() {
+		stopWords := make(map[string]bool)
+		for _, word := range languages[code].StopWords {
+			stopWords[word] = true
+		}
+		count := 0
+		for _, token := range tokens {
+			if stopWords[token.Text] {
+				count++
+			}
+		}
+		if count > bestCount || (count == bestCount && count > 0 && code == fallback) {
+			best, bestCount = code, count
+		}
+	}
+	return best
+}
+
+func sortedLanguageCodes() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// AffixStemmer is a light stemmer that strips the longest matching prefix and
+// suffix, as long as at least MinStem characters remain.
+type AffixStemmer struct {
+	Prefixes []string
+	Suffixes []string
+	MinStem  int
+}
+
+func (s AffixStemmer) Normalize(token Token) Token {
+	if token.Stop {
+		return token
+	}
+	word := token.Text
+	if suffix := longestAffix(s.Suffixes, word, s.MinStem, strings.HasSuffix); suffix != "" {
+		word = strings.TrimSuffix(word, suffix)
+	}
+	if prefix := longestAffix(s.Prefixes, word, s.MinStem, strings.HasPrefix); prefix != "" {
+		word = strings.TrimPrefix(word, prefix)
+	}
+	token.Text = word
+	return token
+}
+
+func longestAffix(affixes []string, word string, minStem int, has func(string, string) bool) string {
+	longest := ""
+	for _, affix := range affixes {
+		if len(affix) > len(longest) && has(word, affix) && len(word)-len(affix) >= minStem {
+			longest = affix
+		}
+	}
+	return longest
+}
+
+func init() {
+	RegisterLanguage(Language{
+		Code: "en",
+		Name: "English",
+		StopWords: []string{
+			"the", "is", "at", "which", "on", "a", "an", "and", "or", "but", "in", "with", "to", "for",
+		},
+		Negations: []string{"not", "no", "never", "without", "none", "nothing", "nor", "cannot"},
+		Stemmer:   PorterStemmer{},
+	})
+	RegisterLanguage(Language{
+		Code: "es",
+		Name: "Spanish",
+		StopWords: []string{
+			"el", "la", "los", "las", "un", "una", "unos", "unas", "y", "o", "pero", "de", "del",
+			"en", "con", "por", "para", "que", "es", "son", "se", "su", "sus", "al", "lo", "como",
+		},
+		Negations: []string{"no", "nunca", "jamás", "sin", "ni", "nada"},
+		Stemmer: AffixStemmer{
+			Suffixes: []string{
+				"amientos", "imientos", "amiento", "imiento", "aciones", "uciones", "mente",
+				"acion", "ucion", "adoras", "adores", "ancias", "ador", "ancia", "idad",
+				"ivas", "ivos", "iva", "ivo", "ando", "iendo", "ados", "idas", "idos", "adas",
+				"ado", "ada", "ido", "ida", "es", "os", "as", "s", "a", "o", "e",
+			},
+			MinStem: 3,
+		},
+	})
+	RegisterLanguage(Language{
+		Code: "de",
+		Name: "German",
+		StopWords: []string{
+			"der", "die", "das", "den", "dem", "des", "ein", "eine", "einen", "einem", "einer",
+			"und", "oder", "aber", "ist", "sind", "mit", "von", "zu", "im", "in", "auf", "fur",
+			"nicht", "auch", "es", "sie", "wir", "ich",
+		},
+		Negations: []string{"nicht", "kein", "keine", "keinen", "keinem", "keiner", "nie", "niemals", "ohne"},
+		Stemmer: AffixStemmer{
+			Suffixes: []string{
+				"ungen", "heiten", "keiten", "ung", "heit", "keit", "lich", "isch",
+				"ern", "em", "en", "er", "es", "e", "s", "n",
+			},
+			MinStem: 3,
+		},
+	})
+	RegisterLanguage(Language{
+		Code: "id",
+		Name: "Indonesian",
+		StopWords: []string{
+			"yang", "dan", "di", "ke", "dari", "ini", "itu", "dengan", "untuk", "pada", "adalah",
+			"atau", "juga", "dalam", "akan", "tidak", "ada", "oleh", "sebagai", "saya", "kami",
+		},
+		Negations: []string{"tidak", "bukan", "tanpa", "belum", "jangan", "tak"},
+		Stemmer: AffixStemmer{
+			Prefixes: []string{"meng", "meny", "mem", "men", "me", "peng", "pem", "pen", "pe", "ber", "ter", "di", "ke", "se"},
+			Suffixes: []string{"kan", "an", "i", "lah", "kah", "nya", "pun"},
+			MinStem:  3,
+		},
+	})
+}
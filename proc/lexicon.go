@@ -0,0 +1,100 @@
+package proc
+
+import (
+	"fmt"
+	"strings"
+)
+
+const lexiconPrefix = "@"
+
+func validLexiconName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Lexicon returns the name of the lexicon the term references, if its text is
+// "@name".
+func (t Term) Lexicon() (string, bool) {
+	name, ok := strings.CutPrefix(t.Text, lexiconPrefix)
+	return name, ok && validLexiconName(name)
+}
+
+// ExpandLexicons returns the categories with every lexicon reference in their
+// keywords, phrases and excluders replaced by the lexicon's terms, each taking
+// the match mode, weight and fuzziness of the reference. It fails on a
+// reference to a lexicon that does not exist.
+func ExpandLexicons(categories []Category, lexicons []Lexicon) ([]Category, error) {
+	byName := lexiconMap(lexicons)
+	for _, category := range categories {
+		for _, list := range [][]Term{category.Keywords, category.Phrases, category.Excluders} {
+			for _, term := range list {
+				if name, ok := term.Lexicon(); ok && byName[name] == nil {
+					return nil, fmt.Errorf("category %q: unknown lexicon %q", category.Name, name)
+				}
+			}
+		}
+	}
+	return expandLexicons(categories, byName), nil
+}
+
+// LexiconUsers returns the names of the categories that reference a lexicon.
+func LexiconUsers(categories []Category, name string) []string {
+	users := make([]string, 0)
+	for _, category := range categories {
+	lists:
+		for _, list := range [][]Term{category.Keywords, category.Phrases, category.Excluders} {
+			for _, term := range list {
+				if n, ok := term.Lexicon(); ok && n == name {
+					users = append(users, category.Name)
+					break lists
+				}
+			}
+		}
+	}
+	return users
+}
+
+func lexiconMap(lexicons []Lexicon) map[string][]string {
+	byName := make(map[string][]string, len(lexicons))
+	for _, lexicon := range lexicons {
+		byName[lexicon.Name] = lexicon.Terms
+	}
+	return byName
+}
+
+// expandLexicons expands references as ExpandLexicons does, dropping those to
+// unknown lexicons.
+func expandLexicons(categories []Category, lexicons map[string][]string) []Category {
+	expanded := make([]Category, len(categories))
+	for i, category := range categories {
+		category.Keywords = expandTerms(category.Keywords, lexicons)
+		category.Phrases = expandTerms(category.Phrases, lexicons)
+		category.Excluders = expandTerms(category.Excluders, lexicons)
+		expanded[i] = category
+	}
+	return expanded
+}
+
+func expandTerms(terms []Term, lexicons map[string][]string) []Term {
+	expanded := make([]Term, 0, len(terms))
+	for _, term := range terms {
+		name, ok := term.Lexicon()
+		if !ok {
+			expanded = append(expanded, term)
+			continue
+		}
+		for _, text := range lexicons[name] {
+			t := term
+			t.Text = text
+			expanded = append(expanded, t)
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,361 @@
+package proc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a problem Lint found in a category's rules. Other names the
+// second category involved, if any.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Category string   `json:"category"`
+	Other    string   `json:"other,omitempty"`
+	Rule     string   `json:"rule,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Category, f.Message)
+}
+
+// lintRule is a keyword, phrase or excluder with its words normalized for
+// one language. key identifies the normalized rule across categories.
+type lintRule struct {
+	category string
+	language string
+	kind     string
+	term     Term
+	words    []string
+	key      string
+}
+
+type linter struct {
+	only      map[string]bool
+	pipelines map[string]Pipeline
+	findings  []Finding
+	reported  map[Finding]bool
+}
+
+// Lint reports rules that are empty, duplicated, contradict each other or can
+// never match. Errors are rules that are certainly wrong; warnings are rules
+// that are probably not doing what their author meant. Rules are compared
+// after normalization with the pipeline of every language their category
+// applies to, so "Programs" and "program" count as the same keyword.
+func Lint(categories []Category) []Finding {
+	return lint(categories, nil)
+}
+
+// LintChanges lints like Lint but only reports what involves the named
+// categories: problems within them and conflicts between them and any other
+// category. Pairs of other categories are not compared.
+func LintChanges(categories []Category, names []string) []Finding {
+	only := make(map[string]bool, len(names))
+	for _, name := range names {
+		only[name] = true
+	}
+	return lint(categories, only)
+}
+
+func lint(categories []Category, only map[string]bool) []Finding {
+	l := &linter{
+		only:      only,
+		pipelines: make(map[string]Pipeline),
+		findings:  make([]Finding, 0),
+		reported:  make(map[Finding]bool),
+	}
+
+	// Categories in scope go first. The rules of the others only matter
+	// where they meet a rule in scope, so they are normalized only in the
+	// languages in scope and grouped only under keys already seen.
+	groups := make(map[string][]lintRule)
+	order := make([]string, 0)
+	inScope, outOfScope := make([]lintRule, 0), make([]lintRule, 0)
+	languages := make(map[string]bool)
+	for _, category := range categories {
+		if !l.inScope(category.Name) {
+			continue
+		}
+		for _, language := range lintLanguages(category) {
+			languages[language] = true
+		}
+		for _, r := range l.category(category, lintLanguages(category), true) {
+			if groups[r.key] == nil {
+				order = append(order, r.key)
+			}
+			groups[r.key] = append(groups[r.key], r)
+		}
+		inScope = append(inScope, rawRules(category)...)
+	}
+	for _, category := range categories {
+		if l.inScope(category.Name) {
+			continue
+		}
+		shared := make([]string, 0)
+		for _, language := range lintLanguages(category) {
+			if languages[language] {
+				shared = append(shared, language)
+			}
+		}
+		for _, r := range l.category(category, shared, false) {
+			if groups[r.key] != nil {
+				groups[r.key] = append(groups[r.key], r)
+			}
+		}
+		outOfScope = append(outOfScope, rawRules(category)...)
+	}
+
+	for _, key := range order {
+		l.group(groups[key])
+	}
+	for _, a := range inScope {
+		if a.term.Match != MatchSubstring {
+			continue
+		}
+		for _, b := range inScope {
+			l.substring(a, b)
+		}
+		for _, b := range outOfScope {
+			l.substring(a, b)
+		}
+	}
+	for _, a := range outOfScope {
+		if a.term.Match != MatchSubstring {
+			continue
+		}
+		for _, b := range inScope {
+			l.substring(a, b)
+		}
+	}
+	return l.findings
+}
+
+// rawRules returns the non-empty keywords, phrases and excluders of a
+// category as written.
+func rawRules(category Category) []lintRule {
+	rules := make([]lintRule, 0)
+	for _, list := range lintLists(category) {
+		for _, term := range list.terms {
+			if strings.TrimSpace(term.Text) != "" {
+				rules = append(rules, lintRule{category: category.Name, kind: list.kind, term: term})
+			}
+		}
+	}
+	return rules
+}
+
+func (l *linter) inScope(category string) bool {
+	return l.only == nil || l.only[category]
+}
+
+func (l *linter) report(severity Severity, category, other, rule, format string, args ...any) {
+	finding := Finding{
+		Severity: severity, Category: category, Other: other, Rule: rule, Message: fmt.Sprintf(format, args...),
+	}
+	if !l.reported[finding] {
+		l.reported[finding] = true
+		l.findings = append(l.findings, finding)
+	}
+}
+
+func (l *linter) pipeline(language string) Pipeline {
+	pipeline, ok := l.pipelines[language]
+	if !ok {
+		registered, _ := LookupLanguage(language)
+		pipeline = registered.Pipeline()
+		l.pipelines[language] = pipeline
+	}
+	return pipeline
+}
+
+type lintList struct {
+	kind  string
+	terms []Term
+}
+
+func lintLists(category Category) []lintList {
+	return []lintList{{"keyword", category.Keywords}, {"phrase", category.Phrases}, {"excluder", category.Excluders}}
+}
+
+// lintLanguages returns the registered languages a category applies to.
+func lintLanguages(category Category) []string {
+	if len(category.Languages) == 0 {
+		return LanguageCodes()
+	}
+	languages := make([]string, 0, len(category.Languages))
+	for _, language := range category.Languages {
+		if _, ok := LookupLanguage(language); ok {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// category returns the distinct rules of a category normalized for each of
+// the languages, checking the category on its own as well when check is set.
+func (l *linter) category(category Category, languages []string, check bool) []lintRule {
+	rules := make([]lintRule, 0)
+	for _, language := range languages {
+		pipeline := l.pipeline(language)
+		seen := make(map[string]string)
+		start := len(rules)
+		for _, list := range lintLists(category) {
+			for _, term := range list.terms {
+				if strings.TrimSpace(term.Text) == "" {
+					if check {
+						l.report(SeverityError, category.Name, "", term.Text, "empty %s", list.kind)
+					}
+					continue
+				}
+				words := pipeline.terms(term.Text)
+				if len(words) == 0 {
+					if check {
+						l.report(SeverityError, category.Name, "", term.Text, "%s %q has no words and never matches", list.kind, term.Text)
+					}
+					continue
+				}
+				r := lintRule{
+					category: category.Name, language: language, kind: list.kind, term: term,
+					words: words, key: language + "\x00" + strings.Join(words, " "),
+				}
+				if !check {
+					rules = append(rules, r)
+					continue
+				}
+				if kind, ok := seen[r.key]; ok {
+					if (kind == "excluder") != (list.kind == "excluder") {
+						l.report(SeverityError, category.Name, "", term.Text, "%q is both a %s and an excluder, so the category excludes itself", term.Text, kind)
+					} else {
+						l.report(SeverityWarning, category.Name, "", term.Text, "duplicate %s %q", list.kind, term.Text)
+					}
+					continue
+				}
+				seen[r.key] = list.kind
+				rules = append(rules, r)
+			}
+		}
+
+		if check {
+			for _, a := range rules[start:] {
+				for _, b := range rules[start:] {
+					if a.key != b.key && a.term.Match != MatchSubstring && b.kind != "excluder" && containsWords(b.words, a.words) {
+						l.report(SeverityWarning, a.category, "", a.term.Text, "%s %q also matches wherever %s %q does, so both score", a.kind, a.term.Text, b.kind, b.term.Text)
+					}
+				}
+			}
+		}
+		if check {
+			l.contexts(category, pipeline)
+		}
+	}
+
+	if check {
+		for _, pattern := range category.Patterns {
+			if re, err := pattern.Compile(); err == nil && re.MatchString("") {
+				l.report(SeverityError, category.Name, "", pattern.Expr, "pattern %q matches the empty string", pattern.Expr)
+			}
+		}
+	}
+	return rules
+}
+
+func (l *linter) contexts(category Category, pipeline Pipeline) {
+	contexts := make([]string, 0, len(category.Contexts))
+	for word := range category.Contexts {
+		contexts = append(contexts, word)
+	}
+	sort.Strings(contexts)
+	for _, word := range contexts {
+		l.contextWord(pipeline, category.Name, "context word", word)
+		related := make(map[string]bool)
+		for _, r := range category.Contexts[word].Related {
+			l.contextWord(pipeline, category.Name, "related word", r)
+			if related[r] {
+				l.report(SeverityWarning, category.Name, "", r, "duplicate related word %q for context %q", r, word)
+			}
+			related[r] = true
+		}
+		if len(category.Contexts[word].Related) == 0 {
+			l.report(SeverityWarning, category.Name, "", word, "context %q has no related words and never scores", word)
+		}
+	}
+}
+
+func (l *linter) contextWord(pipeline Pipeline, category, kind, word string) {
+	switch {
+	case strings.TrimSpace(word) == "":
+		l.report(SeverityError, category, "", word, "empty %s", kind)
+	case len(pipeline.terms(word)) == 0:
+		l.report(SeverityError, category, "", word, "%s %q has no words and never matches", kind, word)
+	case word != strings.ToLower(word):
+		l.report(SeverityWarning, category, "", word, "%s %q is not lowercase", kind, word)
+	}
+}
+
+// group reports the conflicts between rules of different categories that
+// normalize to the same words. Only pairs with a side in scope are compared.
+func (l *linter) group(rules []lintRule) {
+	if len(rules) < 2 {
+		return
+	}
+	for _, a := range rules {
+		if !l.inScope(a.category) {
+			continue
+		}
+		for _, b := range rules {
+			l.shared(a, b)
+			if !l.inScope(b.category) {
+				l.shared(b, a)
+			}
+		}
+	}
+}
+
+func (l *linter) shared(a, b lintRule) {
+	switch {
+	case a.category == b.category:
+	case a.kind != "excluder" && b.kind != "excluder" && a.category < b.category:
+		l.report(SeverityWarning, a.category, b.category, a.term.Text, "%s %q is also a %s of %q", a.kind, a.term.Text, b.kind, b.category)
+	case a.kind == "excluder" && b.kind != "excluder":
+		l.report(SeverityWarning, a.category, b.category, a.term.Text, "excluder %q is also a %s of %q, so the two categories cancel on it", a.term.Text, b.kind, b.category)
+	}
+}
+
+// substring reports a substring rule a that also matches inside rule b.
+func (l *linter) substring(a, b lintRule) {
+	text, other := strings.ToLower(a.term.Text), strings.ToLower(b.term.Text)
+	if text == other || !strings.Contains(other, text) {
+		return
+	}
+	otherCategory := b.category
+	if a.category == b.category {
+		otherCategory = ""
+	}
+	l.report(SeverityWarning, a.category, otherCategory, a.term.Text, "substring %s %q also matches inside %s %q of %q", a.kind, a.term.Text, b.kind, b.term.Text, b.category)
+}
+
+// containsWords reports whether needle appears as consecutive words of
+// haystack.
+func containsWords(haystack, needle []string) bool {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j, word := range needle {
+			if haystack[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package proc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	categories := []Category{
+		{
+			Name:      "Technology",
+			Keywords:  []Term{{Text: "computer"}, {Text: "code"}, {Text: "Computers"}, {Text: " "}},
+			Phrases:   []Term{{Text: "computer science"}},
+			Excluders: []Term{{Text: "cook"}, {Text: "code"}},
+			Contexts: map[string]Context{
+				"Apple": {Related: []string{"iphone", "mac"}},
+			},
+		},
+		{
+			Name:     "Food and Cooking",
+			Keywords: []Term{{Text: "cook"}, {Text: "computer"}, {Text: "cook", Match: MatchSubstring}},
+			Phrases:  []Term{{Text: "cookbook review"}},
+		},
+	}
+
+	want := map[string]Severity{
+		"Technology: empty keyword": SeverityError,
+		`Technology: "code" is both a keyword and an excluder, so the category excludes itself`:                         SeverityError,
+		`Technology: duplicate keyword "Computers"`:                                                                     SeverityWarning,
+		`Technology: context word "Apple" is not lowercase`:                                                             SeverityWarning,
+		`Technology: keyword "computer" also matches wherever phrase "computer science" does, so both score`:            SeverityWarning,
+		`Technology: excluder "cook" is also a keyword of "Food and Cooking", so the two categories cancel on it`:       SeverityWarning,
+		`Food and Cooking: keyword "computer" is also a keyword of "Technology"`:                                        SeverityWarning,
+		`Food and Cooking: substring keyword "cook" also matches inside phrase "cookbook review" of "Food and Cooking"`: SeverityWarning,
+		`Food and Cooking: duplicate keyword "cook"`:                                                                    SeverityWarning,
+	}
+
+	got := make(map[string]Severity)
+	for _, finding := range Lint(categories) {
+		got[finding.Category+": "+finding.Message] = finding.Severity
+	}
+	for message, severity := range want {
+		if got[message] != severity {
+			t.Errorf("missing %s finding %q", severity, message)
+		}
+	}
+	for message := range got {
+		if _, ok := want[message]; !ok {
+			t.Errorf("unexpected finding %q", message)
+		}
+	}
+}
+
+func TestLintLanguages(t *testing.T) {
+	categories := []Category{
+		{Name: "Deportes", Languages: []string{"es"}, Keywords: Terms("running"), Excluders: Terms("run")},
+		{Name: "Sports", Languages: []string{"en"}, Keywords: Terms("runs")},
+	}
+	if findings := Lint(categories); len(findings) != 0 {
+		t.Errorf("Lint() = %v, want no findings across languages", findings)
+	}
+
+	categories[0].Languages = nil
+	findings := Lint(categories)
+	if len(findings) == 0 || findings[0].Severity != SeverityError {
+		t.Errorf("Lint() = %v, want the English stems to clash", findings)
+	}
+}
+
+func TestLintChanges(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("computer"), Excluders: Terms("cook")},
+		{Name: "Food", Keywords: Terms("cook", "computer")},
+		{Name: "Draft", Keywords: Terms("recipe", "recipe")},
+	}
+	findings := LintChanges(categories, []string{"Draft"})
+	if len(findings) != 1 || findings[0].Category != "Draft" {
+		t.Errorf("LintChanges() = %v, want only the duplicate in Draft", findings)
+	}
+
+	findings = LintChanges(categories, []string{"Food"})
+	if len(findings) != 2 {
+		t.Errorf("LintChanges() = %v, want the two conflicts involving Food", findings)
+	}
+}
+
+func BenchmarkLint(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000} {
+		categories := make([]Category, size)
+		for i := range categories {
+			categories[i] = Category{
+				Name:      fmt.Sprintf("Category%d", i),
+				Keywords:  Terms(fmt.Sprintf("kw%da", i), fmt.Sprintf("kw%db", i), "shared"),
+				Phrases:   Terms(fmt.Sprintf("p%d x%d", i, i)),
+				Excluders: Terms(fmt.Sprintf("ex%d", i)),
+			}
+		}
+
+		b.Run(fmt.Sprintf("categories=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				LintChanges(categories, []string{"Category0"})
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package proc
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+type MatchType string
+
+const (
+	MatchKeyword    MatchType = "keyword"
+	MatchPhrase     MatchType = "phrase"
+	MatchPattern    MatchType = "pattern"
+	MatchContext    MatchType = "context"
+	MatchExpression MatchType = "expression"
+	MatchFeature    MatchType = "feature"
+)
+
+// Match is one rule that contributed to a result. Start and End are byte
+// offsets into the input and RuneStart and RuneEnd the same span counted in
+// characters, for clients that index strings by character. Score is the
+// weight the match added, after any negation discount.
+type Match struct {
+	Type      MatchType `json:"type"`
+	Rule      string    `json:"rule"`
+	Text      string    `json:"text"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	RuneStart int       `json:"runeStart"`
+	RuneEnd   int       `json:"runeEnd"`
+	Score     float64   `json:"score"`
+	Fuzzy     bool      `json:"fuzzy,omitempty"`
+	Negated   bool      `json:"negated,omitempty"`
+}
+
+// String renders the match the way matches used to be listed, e.g.
+// "software (fuzzy: sofware)" or "/CVE-\d+/: CVE-2021".
+func (m Match) String() string {
+	var s string
+	switch {
+	case m.Type == MatchPattern:
+		s = fmt.Sprintf("/%s/: %s", m.Rule, m.Text)
+	case m.Type == MatchExpression:
+		s = "expression: " + m.Rule
+	case m.Fuzzy:
+		s = fmt.Sprintf("%s (fuzzy: %s)", m.Rule, m.Text)
+	default:
+		s = m.Rule
+	}
+	if m.Negated {
+		s += " (negated)"
+	}
+	return s
+}
+
+// UnmarshalJSON also accepts the plain strings stored before matches carried
+// spans; those keep only the rule.
+func (m *Match) UnmarshalJSON(data []byte) error {
+	var rule string
+	if err := json.Unmarshal(data, &rule); err == nil {
+		*m = Match{Rule: rule}
+		return nil
+	}
+	type match Match
+	var v match
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = Match(v)
+	return nil
+}
+
+// span builds a match covering text[from:to].
+func (d document) span(matchType MatchType, rule string, from, to int) Match {
+	m := Match{Type: matchType, Rule: rule, Start: from, End: to}
+	if from >= 0 && to <= len(d.text) && from <= to {
+		m.Text = d.text[from:to]
+		m.RuneStart = utf8.RuneCountInString(d.text[:from])
+		m.RuneEnd = m.RuneStart + utf8.RuneCountInString(m.Text)
+	}
+	return m
+}
@@ -0,0 +1,159 @@
+package proc
+
+import (
+	"strings"
+	"unicode"
+)
+
+// matcher is an Aho-Corasick automaton over token sequences. Every rule of
+// every category is compiled into it once, so a single pass over the input
+// tokens reports all rule hits regardless of how many categories exist.
+type matcher struct {
+	nodes      []acNode
+	patterns   []acPattern
+	substrings []substringPattern
+}
+
+type acNode struct {
+	next    map[string]int
+	fail    int
+	pattern int
+	out     []int
+}
+
+type acPattern struct {
+	length int
+	rules  []int
+}
+
+type substringPattern struct {
+	text  string
+	rules []int
+}
+
+// hit is one occurrence of a pattern. start and end are token indexes, or -1
+// for hits not aligned to tokens; from and to are byte offsets in the text.
+type hit struct {
+	rules []int
+	start int
+	end   int
+	from  int
+	to    int
+}
+
+func newMatcher() *matcher {
+	return &matcher{nodes: []acNode{{next: make(map[string]int), pattern: -1}}}
+}
+
+func (m *matcher) addSequence(seq []string, rule int) {
+	node := 0
+	for _, word := range seq {
+		child, ok := m.nodes[node].next[word]
+		if !ok {
+			child = len(m.nodes)
+			m.nodes = append(m.nodes, acNode{next: make(map[string]int), pattern: -1})
+			m.nodes[node].next[word] = child
+		}
+		node = child
+	}
+
+	if pattern := m.nodes[node].pattern; pattern >= 0 {
+		m.patterns[pattern].rules = append(m.patterns[pattern].rules, rule)
+		return
+	}
+	m.nodes[node].pattern = len(m.patterns)
+	m.nodes[node].out = []int{len(m.patterns)}
+	m.patterns = append(m.patterns, acPattern{length: len(seq), rules: []int{rule}})
+}
+
+func (m *matcher) addSubstring(text string, rule int) {
+	for i := range m.substrings {
+		if m.substrings[i].text == text {
+			m.substrings[i].rules = append(m.substrings[i].rules, rule)
+			return
+		}
+	}
+	m.substrings = append(m.substrings, substringPattern{text: text, rules: []int{rule}})
+}
+
+// build computes failure links breadth-first and merges each node's output
+// with the outputs reachable through its failure link.
+func (m *matcher) build() {
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for word, child := range m.nodes[node].next {
+			fail := m.nodes[node].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[word]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if next, ok := m.nodes[fail].next[word]; ok && next != child {
+				fail = next
+			}
+			m.nodes[child].fail = fail
+			m.nodes[child].out = append(m.nodes[child].out, m.nodes[fail].out...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+func (m *matcher) scan(tokens []Token) []hit {
+	hits := make([]hit, 0)
+	node := 0
+	for i, token := range tokens {
+		for node != 0 {
+			if _, ok := m.nodes[node].next[token.Text]; ok {
+				break
+			}
+			node = m.nodes[node].fail
+		}
+		if next, ok := m.nodes[node].next[token.Text]; ok {
+			node = next
+		}
+		for _, pattern := range m.nodes[node].out {
+			p := m.patterns[pattern]
+			start := i + 1 - p.length
+			hits = append(hits, hit{rules: p.rules, start: start, end: i + 1, from: tokens[start].Start, to: token.End})
+		}
+	}
+	return hits
+}
+
+func (m *matcher) scanSubstrings(text string) []hit {
+	hits := make([]hit, 0)
+	if len(m.substrings) == 0 {
+		return hits
+	}
+	lower, offsets := lowerOffsets(text)
+	for _, substring := range m.substrings {
+		if from := strings.Index(lower, substring.text); from >= 0 {
+			to := from + len(substring.text)
+			hits = append(hits, hit{rules: substring.rules, start: -1, end: -1, from: offsets[from], to: offsets[to]})
+		}
+	}
+	return hits
+}
+
+// lowerOffsets lowercases text rune by rune. Lowercasing can change the
+// length of a rune, so offsets maps each byte of the result, and its end,
+// back to the offset in text of the rune it came from.
+func lowerOffsets(text string) (string, []int) {
+	var b strings.Builder
+	offsets := make([]int, 0, len(text)+1)
+	for i, r := range text {
+		n := b.Len()
+		b.WriteRune(unicode.ToLower(r))
+		for range b.Len() - n {
+			offsets = append(offsets, i)
+		}
+	}
+	return b.String(), append(offsets, len(text))
+}
@@ -0,0 +1,57 @@
+package proc
+
+import "sort"
+
+// NegationConfig controls how matches preceded by a negation cue ("not",
+// "never", "without", ...) are treated. A match is negated when a cue appears
+// within Window tokens before it; its score is then multiplied by Factor, so
+// a Factor of 0 drops it. Cues default to the language's Negations.
+type NegationConfig struct {
+	Cues   []string
+	Window int
+	Factor float64
+}
+
+var DefaultNegation = NegationConfig{
+	Window: 3,
+	Factor: 0,
+}
+
+func (sc *RuleClassifier) negationCues(language Language, pipeline Pipeline) map[string]bool {
+	cues := sc.negation.Cues
+	if cues == nil {
+		cues = language.Negations
+	}
+
+	normalized := make(map[string]bool)
+	for _, cue := range cues {
+		for _, token := range pipeline.Tokenize(cue) {
+			normalized[token.Text] = true
+		}
+	}
+	return normalized
+}
+
+// negated reports whether a negation cue precedes the hit within its sentence
+// or clause. Hits that are not aligned to tokens start at the first token
+// after their byte offset.
+func (rs *ruleSet) negated(tokens []Token, h hit) bool {
+	if rs.negationWindow <= 0 {
+		return false
+	}
+	start := h.start
+	if start < 0 {
+		start = sort.Search(len(tokens), func(i int) bool {
+			return tokens[i].End > h.from
+		})
+	}
+	for i := start - 1; i >= max(0, start-rs.negationWindow); i-- {
+		if i+1 < len(tokens) && tokens[i+1].Boundary {
+			break
+		}
+		if rs.negations[tokens[i].Text] {
+			return true
+		}
+	}
+	return false
+}
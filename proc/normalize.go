@@ -0,0 +1,127 @@
+package proc
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Token is a word of the input. Boundary is set when a sentence or clause
+// ends between the previous token and this one.
+type Token struct {
+	Text     string
+	Start    int
+	End      int
+	Stop     bool
+	Boundary bool
+}
+
+// Normalizer transforms a single token. Normalizers are chained in a Pipeline
+// that is applied to rule text and to input text alike, so both always agree.
+type Normalizer interface {
+	Normalize(token Token) Token
+}
+
+type Pipeline []Normalizer
+
+func (p Pipeline) Normalize(token Token) Token {
+	for _, normalizer := range p {
+		token = normalizer.Normalize(token)
+	}
+	return token
+}
+
+// Tokenize splits text into letter/number runs, keeping their byte offsets,
+// and normalizes each one. Stop words stay in the stream, flagged, so phrases
+// spanning them still line up.
+func (p Pipeline) Tokenize(text string) []Token {
+	tokens := make([]Token, 0)
+	start := -1
+	boundary := false
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			tokens = append(tokens, p.Normalize(Token{Text: text[start:i], Start: start, End: i, Boundary: boundary}))
+			start = -1
+			boundary = false
+		}
+		if !inWord && len(tokens) > 0 && strings.ContainsRune(".!?;:", r) {
+			boundary = true
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, p.Normalize(Token{Text: text[start:], Start: start, End: len(text), Boundary: boundary}))
+	}
+	return tokens
+}
+
+func (p Pipeline) terms(text string) []string {
+	tokens := p.Tokenize(text)
+	words := make([]string, len(tokens))
+	for i, token := range tokens {
+		words[i] = token.Text
+	}
+	return words
+}
+
+func DefaultPipeline() Pipeline {
+	language, _ := LookupLanguage(DefaultLanguage)
+	return language.Pipeline()
+}
+
+// UnicodeNormalizer applies compatibility folding: the token is decomposed
+// with NFKD, so ligatures and full-width forms become plain letters and
+// accented letters, precomposed or not, become a base letter and combining
+// marks, which are then dropped. Letters with no decomposition, such as "ø"
+// and "ł", are folded to their ASCII look-alikes first.
+type UnicodeNormalizer struct{}
+
+func (UnicodeNormalizer) Normalize(token Token) Token {
+	var folded strings.Builder
+	for _, r := range token.Text {
+		if replacement, ok := unicodeFolds[r]; ok {
+			folded.WriteString(replacement)
+		} else {
+			folded.WriteRune(r)
+		}
+	}
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(folded.String()) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	token.Text = b.String()
+	return token
+}
+
+var unicodeFolds = map[rune]string{
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ħ': "h", 'Ħ': "H", 'ı': "i",
+	'ł': "l", 'Ł': "L", 'ŀ': "l", 'Ŀ': "L", 'ŉ': "n", 'ŧ': "t", 'Ŧ': "T",
+	'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+}
+
+// CaseFolder lowercases the token, expanding the German sharp s so that
+// "Straße" and "STRASSE" agree.
+type CaseFolder struct{}
+
+func (CaseFolder) Normalize(token Token) Token {
+	token.Text = strings.ReplaceAll(strings.ToLower(token.Text), "ß", "ss")
+	return token
+}
+
+// StopWordFilter flags stop words instead of removing them, so token
+// positions stay stable for phrase matching.
+type StopWordFilter struct {
+	Words map[string]bool
+}
+
+func (f StopWordFilter) Normalize(token Token) Token {
+	if f.Words[token.Text] {
+		token.Stop = true
+	}
+	return token
+}
@@ -0,0 +1,105 @@
+package proc
+
+import (
+	"testing"
+)
+
+func TestPorterStem(t *testing.T) {
+	tests := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"generalization": "gener",
+		"electrical":     "electr",
+		"hopeful":        "hope",
+		"goodness":       "good",
+		"allowance":      "allow",
+		"adjustment":     "adjust",
+		"adoption":       "adopt",
+		"probate":        "probat",
+		"rate":           "rate",
+		"controll":       "control",
+		"programs":       "program",
+		"programming":    "program",
+		"programmed":     "program",
+		"v2":             "v2",
+	}
+
+	for word, expected := range tests {
+		if stem := porterStem(word); stem != expected {
+			t.Errorf("porterStem(%q) = %q, want %q", word, stem, expected)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	pipeline := DefaultPipeline()
+
+	tests := []struct {
+		input    string
+		expected string
+		stop     bool
+	}{
+		{input: "Café", expected: "cafe"},
+		{input: "ＰＲＯＧＲＡＭＳ", expected: "program"},
+		{input: "Straße", expected: "strass"},
+		{input: "ﬁles", expected: "file"},
+		{input: "Việt", expected: "viet"},
+		{input: "Vie\u0323\u0302t", expected: "viet"},
+		{input: "Łódź", expected: "lodz"},
+		{input: "The", expected: "the", stop: true},
+	}
+
+	for _, tt := range tests {
+		token := pipeline.Normalize(Token{Text: tt.input})
+		if token.Text != tt.expected || token.Stop != tt.stop {
+			t.Errorf("Normalize(%q) = %q (stop %v), want %q (stop %v)", tt.input, token.Text, token.Stop, tt.expected, tt.stop)
+		}
+	}
+}
+
+func TestClassifierNormalization(t *testing.T) {
+	categories := []Category{
+		{Name: "Technology", Keywords: Terms("program"), Phrases: Terms("cloud computing")},
+		{Name: "Food", Keywords: Terms("café")},
+	}
+
+	classifier := &RuleClassifier{}
+	classifier.Init(categories)
+
+	tests := []struct {
+		input            string
+		expectedCategory string
+	}{
+		{input: "She programmed it herself", expectedCategory: "Technology"},
+		{input: "Several programs crashed", expectedCategory: "Technology"},
+		{input: "Clouds computed nothing", expectedCategory: "Technology"},
+		{input: "Meet me at the CAFE", expectedCategory: "Food"},
+	}
+
+	for _, tt := range tests {
+		result := classifier.Classify(tt.input)
+		if result.Category != tt.expectedCategory {
+			t.Errorf("Classify(%q) category = %v, want %v", tt.input, result.Category, tt.expectedCategory)
+		}
+	}
+
+	t.Run("Custom pipeline", func(t *testing.T) {
+		classifier := &RuleClassifier{}
+		classifier.Init(categories, WithPipeline(Pipeline{CaseFolder{}}))
+		if result := classifier.Classify("Several programs crashed"); result.Category != "Unknown" {
+			t.Errorf("Classify() category = %v, want Unknown without stemming", result.Category)
+		}
+	})
+}
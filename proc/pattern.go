@@ -0,0 +1,69 @@
+package proc
+
+import (
+	"encoding/json"
+	"regexp"
+)
+
+// Pattern is a regular-expression rule using RE2 syntax. It is matched
+// against the raw input text, so use (?i) for case-insensitive patterns. In
+// JSON it is either a plain string or an object with a weight.
+type Pattern struct {
+	Expr   string   `json:"pattern"`
+	Weight *float64 `json:"weight,omitempty"`
+}
+
+// Factor scales the pattern weight for this pattern; unset means 1.
+func (p Pattern) Factor() float64 {
+	if p.Weight == nil {
+		return 1
+	}
+	return *p.Weight
+}
+
+func (p Pattern) Compile() (*regexp.Regexp, error) {
+	return regexp.Compile(p.Expr)
+}
+
+func (p Pattern) MarshalJSON() ([]byte, error) {
+	if p.Weight == nil {
+		return json.Marshal(p.Expr)
+	}
+	type pattern Pattern
+	return json.Marshal(pattern(p))
+}
+
+func (p *Pattern) UnmarshalJSON(data []byte) error {
+	var expr string
+	if err := json.Unmarshal(data, &expr); err == nil {
+		*p = Pattern{Expr: expr}
+		return nil
+	}
+	type pattern Pattern
+	var v pattern
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = Pattern(v)
+	return nil
+}
+
+// compiledPattern is a pattern of a rule set. Zero-width matches, such as
+// those of \b, are not hits.
+type compiledPattern struct {
+	re   *regexp.Regexp
+	rule int
+}
+
+func (rs *ruleSet) scanPatterns(text string) []hit {
+	hits := make([]hit, 0)
+	for _, pattern := range rs.patterns {
+		for _, loc := range pattern.re.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			hits = append(hits, hit{rules: []int{pattern.rule}, start: -1, end: -1, from: loc[0], to: loc[1]})
+		}
+	}
+	return hits
+}
@@ -0,0 +1,273 @@
+package proc
+
+// PorterStemmer reduces English words to their stem with the original Porter
+// algorithm, so "programs", "programming" and "programmed" all become
+// "program". Words containing anything but ASCII letters are left untouched.
+type PorterStemmer struct{}
+
+func (PorterStemmer) Normalize(token Token) Token {
+	if !token.Stop {
+		token.Text = porterStem(token.Text)
+	}
+	return token
+}
+
+func porterStem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &porter{b: []byte(word)}
+	s.step1ab()
+	if len(s.b) > 1 {
+		s.step1c()
+		s.step2()
+		s.step3()
+		s.step4()
+		s.step5()
+	}
+	return string(s.b)
+}
+
+// porter holds the word being stemmed in b; the last index of b plays the
+// role of k in Porter's reference implementation, and j marks the end of the
+// stem once a suffix has been matched by ends.
+type porter struct {
+	b []byte
+	j int
+}
+
+func (s *porter) k() int {
+	return len(s.b) - 1
+}
+
+func (s *porter) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		if i == 0 {
+			return true
+		}
+		return !s.cons(i - 1)
+	}
+	return true
+}
+
+// m counts the vowel-consonant sequences in b[0..j].
+func (s *porter) m() int {
+	n := 0
+	i := 0
+	for {
+		if i > s.j {
+			return n
+		}
+		if !s.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > s.j {
+				return n
+			}
+			if s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > s.j {
+				return n
+			}
+			if !s.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+func (s *porter) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *porter) doubleC(j int) bool {
+	if j < 1 || s.b[j] != s.b[j-1] {
+		return false
+	}
+	return s.cons(j)
+}
+
+func (s *porter) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (s *porter) ends(suffix string) bool {
+	n := len(suffix)
+	if n > len(s.b) || string(s.b[len(s.b)-n:]) != suffix {
+		return false
+	}
+	s.j = len(s.b) - n - 1
+	return true
+}
+
+func (s *porter) setTo(suffix string) {
+	s.b = append(s.b[:s.j+1], suffix...)
+}
+
+func (s *porter) r(suffix string) {
+	if s.m() > 0 {
+		s.setTo(suffix)
+	}
+}
+
+func (s *porter) step1ab() {
+	if s.b[s.k()] == 's' {
+		if s.ends("sses") {
+			s.b = s.b[:len(s.b)-2]
+		} else if s.ends("ies") {
+			s.setTo("i")
+		} else if s.b[s.k()-1] != 's' {
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+	} else if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.b = s.b[:s.j+1]
+		switch {
+		case s.ends("at"):
+			s.setTo("ate")
+		case s.ends("bl"):
+			s.setTo("ble")
+		case s.ends("iz"):
+			s.setTo("ize")
+		case s.doubleC(s.k()):
+			switch s.b[s.k()] {
+			case 'l', 's', 'z':
+			default:
+				s.b = s.b[:len(s.b)-1]
+			}
+		default:
+			s.j = s.k()
+			if s.m() == 1 && s.cvc(s.k()) {
+				s.b = append(s.b, 'e')
+			}
+		}
+	}
+}
+
+func (s *porter) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[s.k()] = 'i'
+	}
+}
+
+var porterStep2 = map[byte][][2]string{
+	'a': {{"ational", "ate"}, {"tional", "tion"}},
+	'c': {{"enci", "ence"}, {"anci", "ance"}},
+	'e': {{"izer", "ize"}},
+	'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+	'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+	's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+	't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+	'g': {{"logi", "log"}},
+}
+
+func (s *porter) step2() {
+	for _, rule := range porterStep2[s.b[s.k()-1]] {
+		if s.ends(rule[0]) {
+			s.r(rule[1])
+			return
+		}
+	}
+}
+
+var porterStep3 = map[byte][][2]string{
+	'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+	'i': {{"iciti", "ic"}},
+	'l': {{"ical", "ic"}, {"ful", ""}},
+	's': {{"ness", ""}},
+}
+
+func (s *porter) step3() {
+	for _, rule := range porterStep3[s.b[s.k()]] {
+		if s.ends(rule[0]) {
+			s.r(rule[1])
+			return
+		}
+	}
+}
+
+var porterStep4 = map[byte][]string{
+	'a': {"al"},
+	'c': {"ance", "ence"},
+	'e': {"er"},
+	'i': {"ic"},
+	'l': {"able", "ible"},
+	'n': {"ant", "ement", "ment", "ent"},
+	'o': {"ion", "ou"},
+	's': {"ism"},
+	't': {"ate", "iti"},
+	'u': {"ous"},
+	'v': {"ive"},
+	'z': {"ize"},
+}
+
+func (s *porter) step4() {
+	if len(s.b) < 2 {
+		return
+	}
+	for _, suffix := range porterStep4[s.b[s.k()-1]] {
+		if !s.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (s.j < 0 || (s.b[s.j] != 's' && s.b[s.j] != 't')) {
+			continue
+		}
+		if s.m() > 1 {
+			s.b = s.b[:s.j+1]
+		}
+		return
+	}
+}
+
+func (s *porter) step5() {
+	s.j = s.k()
+	if s.b[s.k()] == 'e' {
+		a := s.m()
+		if a > 1 || (a == 1 && !s.cvc(s.k()-1)) {
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+	s.j = s.k()
+	if s.b[s.k()] == 'l' && s.doubleC(s.k()) && s.m() > 1 {
+		s.b = s.b[:len(s.b)-1]
+	}
+}
@@ -0,0 +1,173 @@
+package proc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CategoryNode is a category together with the categories whose Parent it is.
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children,omitempty"`
+}
+
+// ValidateTaxonomy checks that every parent exists and that no category is
+// its own ancestor.
+func ValidateTaxonomy(categories []Category) error {
+	byName := make(map[string]Category, len(categories))
+	for _, category := range categories {
+		byName[category.Name] = category
+	}
+
+	for _, category := range categories {
+		seen := map[string]bool{category.Name: true}
+		for parent := category.Parent; parent != ""; parent = byName[parent].Parent {
+			if _, ok := byName[parent]; !ok {
+				return fmt.Errorf("category %q: unknown parent %q", category.Name, parent)
+			}
+			if seen[parent] {
+				return fmt.Errorf("category %q: parent cycle through %q", category.Name, parent)
+			}
+			seen[parent] = true
+		}
+	}
+	return nil
+}
+
+// Tree arranges categories under their parents. Categories whose parent is
+// missing, or that sit on a parent cycle, are returned as roots; siblings are
+// sorted by name.
+func Tree(categories []Category) []CategoryNode {
+	names := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		names[category.Name] = true
+	}
+	children := make(map[string][]Category)
+	for _, category := range categories {
+		parent := category.Parent
+		if !names[parent] || parent == category.Name {
+			parent = ""
+		}
+		children[parent] = append(children[parent], category)
+	}
+
+	var build func(parent string, seen map[string]bool) []CategoryNode
+	build = func(parent string, seen map[string]bool) []CategoryNode {
+		nodes := make([]CategoryNode, 0, len(children[parent]))
+		for _, category := range children[parent] {
+			if seen[category.Name] {
+				continue
+			}
+			seen[category.Name] = true
+			nodes = append(nodes, CategoryNode{Category: category, Children: build(category.Name, seen)})
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].Name < nodes[j].Name
+		})
+		return nodes
+	}
+	seen := make(map[string]bool)
+	roots := build("", seen)
+	for _, category := range categories {
+		if !seen[category.Name] {
+			seen[category.Name] = true
+			roots = append(roots, CategoryNode{Category: category, Children: build(category.Name, seen)})
+		}
+	}
+	return roots
+}
+
+// categoryPaths returns the names from the root down to each category. A
+// parent that is missing or closes a cycle ends the path.
+func categoryPaths(categories []Category) [][]string {
+	byName := make(map[string]Category, len(categories))
+	for _, category := range categories {
+		byName[category.Name] = category
+	}
+
+	paths := make([][]string, len(categories))
+	for i, category := range categories {
+		path := []string{category.Name}
+		seen := map[string]bool{category.Name: true}
+		for parent := category.Parent; parent != "" && !seen[parent]; parent = byName[parent].Parent {
+			if _, ok := byName[parent]; !ok {
+				break
+			}
+			seen[parent] = true
+			path = append(path, parent)
+		}
+		for l, r := 0, len(path)-1; l < r; l, r = l+1, r-1 {
+			path[l], path[r] = path[r], path[l]
+		}
+		paths[i] = path
+	}
+	return paths
+}
+
+// inherit returns the categories with their ancestors' keywords, phrases,
+// contexts, excluders and patterns added to their own. A child's context
+// replaces an ancestor's context for the same word, and a child without an
+// expression takes its nearest ancestor's.
+func inherit(categories []Category, paths [][]string) []Category {
+	byName := make(map[string]Category, len(categories))
+	for _, category := range categories {
+		byName[category.Name] = category
+	}
+
+	expanded := make([]Category, len(categories))
+	for i, category := range categories {
+		path := paths[i]
+		for j := len(path) - 2; j >= 0; j-- {
+			ancestor := byName[path[j]]
+			category.Keywords = inheritTerms(category.Keywords, ancestor.Keywords)
+			category.Phrases = inheritTerms(category.Phrases, ancestor.Phrases)
+			category.Excluders = inheritTerms(category.Excluders, ancestor.Excluders)
+			category.Patterns = inheritPatterns(category.Patterns, ancestor.Patterns)
+			if len(ancestor.Contexts) > 0 {
+				contexts := make(map[string]Context, len(category.Contexts)+len(ancestor.Contexts))
+				for word, context := range ancestor.Contexts {
+					contexts[word] = context
+				}
+				for word, context := range category.Contexts {
+					contexts[word] = context
+				}
+				category.Contexts = contexts
+			}
+			if category.Expression == "" {
+				category.Expression = ancestor.Expression
+			}
+		}
+		expanded[i] = category
+	}
+	return expanded
+}
+
+// inheritTerms appends the inherited terms the category does not define
+// itself, without touching the category's own slice.
+func inheritTerms(own, inherited []Term) []Term {
+	texts := make(map[string]bool, len(own))
+	for _, term := range own {
+		texts[term.Text] = true
+	}
+	terms := append([]Term(nil), own...)
+	for _, term := range inherited {
+		if !texts[term.Text] {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func inheritPatterns(own, inherited []Pattern) []Pattern {
+	exprs := make(map[string]bool, len(own))
+	for _, pattern := range own {
+		exprs[pattern.Expr] = true
+	}
+	patterns := append([]Pattern(nil), own...)
+	for _, pattern := range inherited {
+		if !exprs[pattern.Expr] {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
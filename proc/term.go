@@ -0,0 +1,62 @@
+package proc
+
+import "encoding/json"
+
+type MatchMode string
+
+const (
+	MatchToken     MatchMode = ""
+	MatchSubstring MatchMode = "substring"
+
+	// matchNone marks rules that are not indexed in the token matcher.
+	matchNone MatchMode = "-"
+)
+
+// Term is a single rule entry. In JSON it is either a plain string, matched on
+// token boundaries with weight 1, or an object carrying per-rule options.
+type Term struct {
+	Text   string    `json:"term"`
+	Match  MatchMode `json:"match,omitempty"`
+	Weight *float64  `json:"weight,omitempty"`
+	Fuzzy  int       `json:"fuzzy,omitempty"`
+}
+
+// Factor scales the rule type's weight for this term; unset means 1. An
+// explicit weight of 0 keeps the term from scoring.
+func (t Term) Factor() float64 {
+	if t.Weight == nil {
+		return 1
+	}
+	return *t.Weight
+}
+
+func Terms(texts ...string) []Term {
+	terms := make([]Term, 0, len(texts))
+	for _, text := range texts {
+		terms = append(terms, Term{Text: text})
+	}
+	return terms
+}
+
+func (t Term) MarshalJSON() ([]byte, error) {
+	if t.Match == MatchToken && t.Weight == nil && t.Fuzzy == 0 {
+		return json.Marshal(t.Text)
+	}
+	type term Term
+	return json.Marshal(term(t))
+}
+
+func (t *Term) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = Term{Text: text}
+		return nil
+	}
+	type term Term
+	var v term
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = Term(v)
+	return nil
+}
@@ -1,26 +1,262 @@
 package proc
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type InputData struct {
-	Items []string `json:"items"`
+	Items     []string `json:"items"`
+	TopN      int      `json:"topN,omitempty"`
+	Threshold float64  `json:"threshold,omitempty"`
+	Language  string   `json:"language,omitempty"`
+	Depth     int      `json:"depth,omitempty"`
+	Backend   string   `json:"backend,omitempty"`
+}
+
+func (d InputData) Options() ClassifyOptions {
+	return ClassifyOptions{TopN: d.TopN, Threshold: d.Threshold, Language: d.Language, Depth: d.Depth}
+}
+
+// ClassifyOptions tunes a single classification. Depth, when set, reports
+// each match as its ancestor at that level of the taxonomy (1 for roots).
+type ClassifyOptions struct {
+	TopN      int
+	Threshold float64
+	Language  string
+	Depth     int
+}
+
+func (o ClassifyOptions) MultiLabel() bool {
+	return o.TopN > 0 || o.Threshold > 0
 }
 
 type Category struct {
-	Name      string              `json:"name"`
-	Keywords  []string            `json:"keywords"`
-	Phrases   []string            `json:"phrases"`
-	Contexts  map[string][]string `json:"contexts"`
-	Excluders []string            `json:"excluders"`
+	Name       string             `json:"name"`
+	Parent     string             `json:"parent,omitempty"`
+	Keywords   []Term             `json:"keywords"`
+	Phrases    []Term             `json:"phrases"`
+	Contexts   map[string]Context `json:"contexts"`
+	Excluders  []Term             `json:"excluders"`
+	Patterns   []Pattern          `json:"patterns,omitempty"`
+	Expression string             `json:"expression,omitempty"`
+	Languages  []string           `json:"languages,omitempty"`
+	Weights    *Weights           `json:"weights,omitempty"`
+	Fuzzy      int                `json:"fuzzy,omitempty"`
+
+	// MinConfidence and MinMatches hold the category back unless it reaches
+	// that confidence with that many distinct matching rules.
+	MinConfidence float64 `json:"minConfidence,omitempty"`
+	MinMatches    int     `json:"minMatches,omitempty"`
+}
+
+func (c Category) Validate() error {
+	if c.Name == "" {
+		return errors.New("category name is required")
+	}
+	if c.Parent == c.Name {
+		return fmt.Errorf("category %q cannot be its own parent", c.Name)
+	}
+	for _, language := range c.Languages {
+		if _, ok := LookupLanguage(language); !ok {
+			return fmt.Errorf("category %q: unknown language %q", c.Name, language)
+		}
+	}
+	if err := c.Weights.Validate(); err != nil {
+		return fmt.Errorf("category %q: %w", c.Name, err)
+	}
+	for _, pattern := range c.Patterns {
+		re, err := pattern.Compile()
+		if err != nil {
+			return fmt.Errorf("category %q: invalid pattern %q: %w", c.Name, pattern.Expr, err)
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("category %q: pattern %q matches the empty string", c.Name, pattern.Expr)
+		}
+		if pattern.Weight != nil && *pattern.Weight < 0 {
+			return fmt.Errorf("category %q: pattern %q has a negative weight", c.Name, pattern.Expr)
+		}
+	}
+	if c.Expression != "" {
+		if _, err := parseExpression(c.Expression); err != nil {
+			return fmt.Errorf("category %q: invalid expression: %w", c.Name, err)
+		}
+	}
+	for word, context := range c.Contexts {
+		if context.Window < 0 {
+			return fmt.Errorf("category %q: context %q has a negative window", c.Name, word)
+		}
+	}
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		return fmt.Errorf("category %q: minimum confidence must be between 0 and 1", c.Name)
+	}
+	if c.MinMatches < 0 {
+		return fmt.Errorf("category %q: minimum matches cannot be negative", c.Name)
+	}
+	if c.Fuzzy < 0 || c.Fuzzy > MaxFuzzyDistance {
+		return fmt.Errorf("category %q: fuzzy distance must be between 0 and %d", c.Name, MaxFuzzyDistance)
+	}
+	for i, terms := range [][]Term{c.Keywords, c.Phrases, c.Excluders} {
+		for _, term := range terms {
+			if term.Weight != nil && *term.Weight < 0 {
+				return fmt.Errorf("category %q: term %q has a negative weight", c.Name, term.Text)
+			}
+			if term.Fuzzy < 0 || term.Fuzzy > MaxFuzzyDistance {
+				return fmt.Errorf("category %q: term %q: fuzzy distance must be between 0 and %d", c.Name, term.Text, MaxFuzzyDistance)
+			}
+			if term.Fuzzy > 0 && i == 2 {
+				return fmt.Errorf("category %q: excluder %q cannot be fuzzy", c.Name, term.Text)
+			}
+			if term.Fuzzy > 0 && !fuzzyCapable(term) {
+				return fmt.Errorf("category %q: term %q: only single words of at least %d letters matched as tokens can be fuzzy", c.Name, term.Text, MinFuzzyLength)
+			}
+		}
+	}
+	return nil
+}
+
+// fuzzyCapable reports whether a term can have fuzzy hits: a single word,
+// long enough, matched as a token.
+func fuzzyCapable(term Term) bool {
+	words := DefaultPipeline().Tokenize(term.Text)
+	return term.Match == MatchToken && len(words) == 1 && len([]rune(words[0].Text)) >= MinFuzzyLength
+}
+
+func (c Category) AppliesTo(language string) bool {
+	if len(c.Languages) == 0 {
+		return true
+	}
+	for _, l := range c.Languages {
+		if l == language {
+			return true
+		}
+	}
+	return false
 }
 
 type CategoryOutputData struct {
 	Categories []Category `json:"categories"`
+	Findings   []Finding  `json:"findings,omitempty"`
+}
+
+type CategoryTreeOutputData struct {
+	Categories []CategoryNode `json:"categories"`
+}
+
+// Transition counts the items a draft category would move from one label to
+// another, with a few of them as examples.
+type Transition struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+type DryRunOutputData struct {
+	Total       int          `json:"total"`
+	Changed     int          `json:"changed"`
+	Transitions []Transition `json:"transitions"`
 }
 
 type ClassificationResult struct {
 	Item       string   `json:"item"`
 	Category   string   `json:"category"`
+	Path       []string `json:"path,omitempty"`
 	Confidence float64  `json:"confidence"`
-	Matches    []string `json:"matches"`
+	Score      float64  `json:"score"`
+	Matches    []Match  `json:"matches"`
+	Labels     []Label  `json:"labels,omitempty"`
+	Language   string   `json:"language,omitempty"`
+	Votes      []Vote   `json:"votes,omitempty"`
+
+	// Abstained is set when some category matched but none was confident
+	// enough to be reported; AbstainReason says which and why.
+	Abstained     bool   `json:"abstained,omitempty"`
+	AbstainReason string `json:"abstainReason,omitempty"`
+}
+
+type Label struct {
+	Category   string   `json:"category"`
+	Path       []string `json:"path,omitempty"`
+	Confidence float64  `json:"confidence"`
+	Score      float64  `json:"score"`
+	Matches    []Match  `json:"matches"`
+}
+
+// Example is a text labeled with its category, used to train statistical
+// backends.
+type Example struct {
+	Text     string `json:"text"`
+	Category string `json:"category"`
+}
+
+func (e Example) Validate() error {
+	if e.Text == "" {
+		return errors.New("example text is required")
+	}
+	if e.Category == "" {
+		return errors.New("example category is required")
+	}
+	return nil
+}
+
+type ExampleOutputData struct {
+	Examples []Example `json:"examples"`
+}
+
+// Lexicon is a named list of terms that categories share by referencing it
+// as "@name" in their keywords, phrases and excluders.
+type Lexicon struct {
+	Name  string   `json:"name"`
+	Terms []string `json:"terms"`
+}
+
+func (l Lexicon) Validate() error {
+	if !validLexiconName(l.Name) {
+		return fmt.Errorf("lexicon name %q must be letters, digits, underscores and hyphens", l.Name)
+	}
+	if len(l.Terms) == 0 {
+		return fmt.Errorf("lexicon %q has no terms", l.Name)
+	}
+	for _, term := range l.Terms {
+		if strings.TrimSpace(term) == "" {
+			return fmt.Errorf("lexicon %q has an empty term", l.Name)
+		}
+		if strings.HasPrefix(term, lexiconPrefix) {
+			return fmt.Errorf("lexicon %q: term %q cannot reference another lexicon", l.Name, term)
+		}
+	}
+	return nil
+}
+
+type LexiconOutputData struct {
+	Lexicons []Lexicon `json:"lexicons"`
+}
+
+// Feedback is a reviewer's correction of a stored classification. Predicted
+// keeps the category the classifier had assigned when it was given.
+type Feedback struct {
+	Item      string    `json:"item"`
+	Category  string    `json:"category"`
+	Predicted string    `json:"predicted"`
+	Reviewer  string    `json:"reviewer"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (f Feedback) Validate() error {
+	if f.Category == "" {
+		return errors.New("feedback category is required")
+	}
+	if f.Reviewer == "" {
+		return errors.New("feedback reviewer is required")
+	}
+	return nil
+}
+
+type FeedbackOutputData struct {
+	Feedback []Feedback `json:"feedback"`
 }
 
 type ClassificationOutputData struct {
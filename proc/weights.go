@@ -0,0 +1,74 @@
+package proc
+
+import "errors"
+
+// WeightProfile holds the score each rule type adds when it matches. Fuzzy
+// scales keyword and phrase weights for hits that were not exact.
+type WeightProfile struct {
+	Keyword    float64 `json:"keyword"`
+	Phrase     float64 `json:"phrase"`
+	Context    float64 `json:"context"`
+	Pattern    float64 `json:"pattern"`
+	Expression float64 `json:"expression"`
+	Fuzzy      float64 `json:"fuzzy"`
+}
+
+var DefaultWeightProfile = WeightProfile{
+	Keyword:    1.0,
+	Phrase:     2.0,
+	Context:    1.5,
+	Pattern:    2.0,
+	Expression: 2.0,
+	Fuzzy:      0.5,
+}
+
+// Weights overrides parts of the classifier's WeightProfile for a single
+// category. Unset fields keep the profile's value.
+type Weights struct {
+	Keyword    *float64 `json:"keyword,omitempty"`
+	Phrase     *float64 `json:"phrase,omitempty"`
+	Context    *float64 `json:"context,omitempty"`
+	Pattern    *float64 `json:"pattern,omitempty"`
+	Expression *float64 `json:"expression,omitempty"`
+	Fuzzy      *float64 `json:"fuzzy,omitempty"`
+}
+
+func (w *Weights) Resolve(profile WeightProfile) WeightProfile {
+	if w == nil {
+		return profile
+	}
+	if w.Keyword != nil {
+		profile.Keyword = *w.Keyword
+	}
+	if w.Phrase != nil {
+		profile.Phrase = *w.Phrase
+	}
+	if w.Context != nil {
+		profile.Context = *w.Context
+	}
+	if w.Pattern != nil {
+		profile.Pattern = *w.Pattern
+	}
+	if w.Expression != nil {
+		profile.Expression = *w.Expression
+	}
+	if w.Fuzzy != nil {
+		profile.Fuzzy = *w.Fuzzy
+	}
+	return profile
+}
+
+func (w *Weights) Validate() error {
+	if w == nil {
+		return nil
+	}
+	for _, weight := range []*float64{w.Keyword, w.Phrase, w.Context, w.Pattern, w.Expression, w.Fuzzy} {
+		if weight != nil && *weight < 0 {
+			return errors.New("weights must not be negative")
+		}
+	}
+	if w.Fuzzy != nil && *w.Fuzzy > 1 {
+		return errors.New("fuzzy weight must not be above 1, so fuzzy hits never outscore exact ones")
+	}
+	return nil
+}
@@ -1,35 +1,150 @@
 package server
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"cfs/analysis"
 	"cfs/db"
 	"cfs/proc"
 )
 
+// Backends that requests and deployments can choose between.
+const (
+	BackendRules    = "rules"
+	BackendBayes    = "bayes"
+	BackendEnsemble = "ensemble"
+)
+
+// rulesOverride is the rule confidence at which the ensemble takes the rules'
+// answer without consulting the Naive Bayes model.
+const rulesOverride = 0.6
+
 type Server struct {
-	db         db.Database
-	classifier proc.Classifier
+	// Backend classifies requests that do not name one. Empty means rules.
+	Backend string
+	// AbstainThreshold is the confidence below which any backend answers
+	// Unknown instead of its best category. Zero never abstains.
+	AbstainThreshold float64
+
+	db       db.Database
+	rules    atomic.Pointer[proc.RuleClassifier]
+	bayes    atomic.Pointer[proc.NaiveBayes]
+	ensemble atomic.Pointer[proc.Ensemble]
+	reloadMu sync.Mutex
+	// writeMu is held by handlers that check the stored categories and then
+	// change them, so no other change slips in between.
+	writeMu sync.Mutex
 }
 
 func (s *Server) Init() error {
+	if s.Backend == "" {
+		s.Backend = BackendRules
+	}
+	if s.Backend != BackendRules && s.Backend != BackendBayes && s.Backend != BackendEnsemble {
+		return fmt.Errorf("unknown backend %q", s.Backend)
+	}
+	if s.AbstainThreshold < 0 || s.AbstainThreshold > 1 {
+		return fmt.Errorf("abstain threshold %v must be between 0 and 1", s.AbstainThreshold)
+	}
+
 	s.db = db.Database{}
 	if err := s.db.Init(); err != nil {
 		return err
 	}
 	s.db.Seed()
-	s.classifier = proc.Classifier{}
+	return s.reloadClassifier()
+}
+
+// reloadClassifier builds new classifiers from the stored categories and
+// examples and swaps them in. Requests holding the previous snapshots keep
+// using them.
+func (s *Server) reloadClassifier() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
 	categories, err := s.db.GetCategories()
 	if err != nil {
 		return err
 	}
-	s.classifier.Init(categories)
+	examples, err := s.db.GetExamples()
+	if err != nil {
+		return err
+	}
+	lexicons, err := s.db.GetLexicons()
+	if err != nil {
+		return err
+	}
+
+	rules, bayes, ensemble := s.buildClassifiers(categories, examples, lexicons)
+	s.rules.Store(rules)
+	s.bayes.Store(bayes)
+	s.ensemble.Store(ensemble)
 	return nil
 }
 
+func (s *Server) buildClassifiers(categories []proc.Category, examples []proc.Example, lexicons []proc.Lexicon) (*proc.RuleClassifier, *proc.NaiveBayes, *proc.Ensemble) {
+	rules := &proc.RuleClassifier{}
+	rules.Init(categories, proc.WithLexicons(lexicons))
+	bayes := &proc.NaiveBayes{}
+	bayes.Init(examples)
+	ensemble := &proc.Ensemble{}
+	ensemble.Init([]proc.Member{
+		{Name: BackendRules, Classifier: rules, Override: rulesOverride},
+		{Name: BackendBayes, Classifier: bayes},
+	})
+	return rules, bayes, ensemble
+}
+
+// Classifier returns the current snapshot of a backend, or of the default
+// backend when name is empty, abstaining below the server's threshold.
+func (s *Server) Classifier(name string) (proc.Classifier, bool) {
+	if name == "" {
+		name = s.Backend
+	}
+	switch name {
+	case BackendRules:
+		return s.abstaining(s.rules.Load()), true
+	case BackendBayes:
+		return s.abstaining(s.bayes.Load()), true
+	case BackendEnsemble:
+		return s.abstaining(s.ensemble.Load()), true
+	}
+	return nil, false
+}
+
+func (s *Server) abstaining(classifier proc.Classifier) proc.Classifier {
+	if s.AbstainThreshold <= 0 {
+		return classifier
+	}
+	return abstainer{Classifier: classifier, threshold: s.AbstainThreshold}
+}
+
+// abstainer answers Unknown when the best category of the classifier it
+// wraps falls below threshold. Rankings are left as they are.
+type abstainer struct {
+	proc.Classifier
+	threshold float64
+}
+
+func (a abstainer) Classify(sentence string) proc.ClassificationResult {
+	return proc.Abstain(a.Classifier.Classify(sentence), a.threshold)
+}
+
+func (a abstainer) ClassifyWith(sentence string, opts proc.ClassifyOptions) proc.ClassificationResult {
+	return proc.Abstain(a.Classifier.ClassifyWith(sentence, opts), a.threshold)
+}
+
 func (s *Server) Close() {
 	s.db.Close()
 }
@@ -40,9 +155,19 @@ func (s *Server) Run() {
 	mux.HandleFunc("GET /cfs/i", s.handleGetClassifications)
 	mux.HandleFunc("POST /cfs/i", s.handleCreateClassifications)
 	mux.HandleFunc("GET /cfs/i/{item}", s.handleGetClassification)
+	mux.HandleFunc("GET /cfs/i/{item}/feedback", s.handleGetFeedback)
+	mux.HandleFunc("POST /cfs/i/{item}/feedback", s.handleCreateFeedback)
 	mux.HandleFunc("GET /cfs/c", s.handleGetCategories)
 	mux.HandleFunc("POST /cfs/c", s.handleCreateCategories)
 	mux.HandleFunc("GET /cfs/c/{category}", s.handleGetCategory)
+	mux.HandleFunc("POST /cfs/c/dry-run", s.handleDryRunCategory)
+	mux.HandleFunc("GET /cfs/suggestions", s.handleGetSuggestions)
+	mux.HandleFunc("GET /cfs/e", s.handleGetExamples)
+	mux.HandleFunc("POST /cfs/e", s.handleCreateExamples)
+	mux.HandleFunc("GET /cfs/lexicons", s.handleGetLexicons)
+	mux.HandleFunc("POST /cfs/lexicons", s.handleCreateLexicons)
+	mux.HandleFunc("GET /cfs/lexicons/{name}", s.handleGetLexicon)
+	mux.HandleFunc("DELETE /cfs/lexicons/{name}", s.handleDeleteLexicon)
 
 	fmt.Println("Server running at http://localhost:8080/")
 	log.Fatal(http.ListenAndServe(":8080", mux))
@@ -76,6 +201,66 @@ func (s *Server) handleGetClassification(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(result)
 }
 
+func (s *Server) handleGetFeedback(w http.ResponseWriter, r *http.Request) {
+	feedback, err := s.db.GetItemFeedback(r.PathValue("item"))
+	if err != nil {
+		http.Error(w, "Failed to get feedback", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proc.FeedbackOutputData{Feedback: feedback})
+}
+
+// handleCreateFeedback records a correction next to the stored result, which
+// is left as the classifier produced it. The correction must be a stored
+// category or Unknown.
+func (s *Server) handleCreateFeedback(w http.ResponseWriter, r *http.Request) {
+	var feedback proc.Feedback
+	if err := json.NewDecoder(r.Body).Decode(&feedback); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	if err := feedback.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.db.GetClassification(r.PathValue("item"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Classification not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get classification", http.StatusInternalServerError)
+		return
+	}
+
+	if feedback.Category != "Unknown" {
+		_, err := s.db.GetCategory(feedback.Category)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, fmt.Sprintf("Unknown category %q", feedback.Category), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to get category", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	feedback.Item = result.Item
+	feedback.Predicted = result.Category
+	feedback.CreatedAt = time.Now().UTC()
+	if err := s.db.AddFeedback(feedback); err != nil {
+		http.Error(w, "Failed to create feedback", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feedback)
+}
+
 func (s *Server) handleCreateClassifications(w http.ResponseWriter, r *http.Request) {
 	var inputData proc.InputData
 	if err := json.NewDecoder(r.Body).Decode(&inputData); err != nil {
@@ -83,15 +268,23 @@ func (s *Server) handleCreateClassifications(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if language := inputData.Language; language != "" && language != proc.AutoLanguage {
+		if _, ok := proc.LookupLanguage(language); !ok {
+			http.Error(w, "Unknown language", http.StatusBadRequest)
+			return
+		}
+	}
+
+	classifier, ok := s.Classifier(inputData.Backend)
+	if !ok {
+		http.Error(w, "Unknown backend", http.StatusBadRequest)
+		return
+	}
+	opts := inputData.Options()
 	results := make([]proc.ClassificationResult, 0)
 	for _, item := range inputData.Items {
-		classification := s.classifier.Classify(item)
-		result := proc.ClassificationResult{
-			Item:       item,
-			Category:   classification.Category,
-			Confidence: classification.Confidence,
-			Matches:    classification.Matches,
-		}
+		result := classifier.ClassifyWith(item, opts)
+		result.Item = item
 		if err := s.db.AddClassification(item, result); err != nil {
 			http.Error(w, "Failed to create classification", http.StatusInternalServerError)
 			return
@@ -113,6 +306,10 @@ func (s *Server) handleGetCategories(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("tree") == "true" {
+		json.NewEncoder(w).Encode(proc.CategoryTreeOutputData{Categories: proc.Tree(categories)})
+		return
+	}
 	json.NewEncoder(w).Encode(proc.CategoryOutputData{Categories: categories})
 }
 
@@ -123,16 +320,161 @@ func (s *Server) handleCreateCategories(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	for _, category := range categories {
+		if err := category.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	existing, err := s.db.GetCategories()
+	if err != nil {
+		http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+		return
+	}
+	merged := mergeCategories(existing, categories)
+	if err := proc.ValidateTaxonomy(merged); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lexicons, err := s.db.GetLexicons()
+	if err != nil {
+		http.Error(w, "Failed to get lexicons", http.StatusInternalServerError)
+		return
+	}
+	expanded, err := proc.ExpandLexicons(merged, lexicons)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	findings, errs := lintCategories(expanded, categories)
+	if len(errs) > 0 {
+		http.Error(w, strings.Join(errs, "\n"), http.StatusBadRequest)
+		return
+	}
+
 	for _, category := range categories {
 		if err := s.db.AddCategory(category); err != nil {
 			http.Error(w, "Failed to create category", http.StatusInternalServerError)
 			return
 		}
 	}
+	if err := s.reloadClassifier(); err != nil {
+		http.Error(w, "Failed to reload classifier", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(proc.CategoryOutputData{Categories: categories})
+	json.NewEncoder(w).Encode(proc.CategoryOutputData{Categories: categories, Findings: findings})
+}
+
+// handleDryRunCategory reclassifies every stored item with a draft category
+// added to, or replacing one of, the stored categories and reports which
+// labels would change, without saving anything. Both sides are classified
+// now with the default backend, so only the draft makes the difference.
+func (s *Server) handleDryRunCategory(w http.ResponseWriter, r *http.Request) {
+	limit := dryRunExamples
+	if v := r.URL.Query().Get("examples"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid examples", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var draft proc.Category
+	if err := json.NewDecoder(r.Body).Decode(&draft); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	if err := draft.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.db.GetCategories()
+	if err != nil {
+		http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+		return
+	}
+	merged := mergeCategories(existing, []proc.Category{draft})
+	if err := proc.ValidateTaxonomy(merged); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lexicons, err := s.db.GetLexicons()
+	if err != nil {
+		http.Error(w, "Failed to get lexicons", http.StatusInternalServerError)
+		return
+	}
+	if _, err := proc.ExpandLexicons(merged, lexicons); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	examples, err := s.db.GetExamples()
+	if err != nil {
+		http.Error(w, "Failed to get examples", http.StatusInternalServerError)
+		return
+	}
+	classifications, err := s.db.GetClassifications()
+	if err != nil {
+		http.Error(w, "Failed to get classifications", http.StatusInternalServerError)
+		return
+	}
+
+	current, _ := s.Classifier("")
+	rules, bayes, ensemble := s.buildClassifiers(merged, examples, lexicons)
+	var proposed proc.Classifier = rules
+	switch s.Backend {
+	case BackendBayes:
+		proposed = bayes
+	case BackendEnsemble:
+		proposed = ensemble
+	}
+	proposed = s.abstaining(proposed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dryRun(current, proposed, classifications, limit))
+}
+
+// dryRunExamples is the number of example items kept per transition unless a
+// request asks for another.
+const dryRunExamples = 5
+
+func dryRun(current, proposed proc.Classifier, classifications []proc.ClassificationResult, limit int) proc.DryRunOutputData {
+	output := proc.DryRunOutputData{Total: len(classifications), Transitions: make([]proc.Transition, 0)}
+	index := make(map[[2]string]int)
+	for _, classification := range classifications {
+		from := current.Classify(classification.Item).Category
+		to := proposed.Classify(classification.Item).Category
+		if from == to {
+			continue
+		}
+		output.Changed++
+
+		key := [2]string{from, to}
+		i, ok := index[key]
+		if !ok {
+			i = len(output.Transitions)
+			index[key] = i
+			output.Transitions = append(output.Transitions, proc.Transition{From: from, To: to, Examples: make([]string, 0)})
+		}
+		t := &output.Transitions[i]
+		t.Count++
+		if len(t.Examples) < limit {
+			t.Examples = append(t.Examples, classification.Item)
+		}
+	}
+
+	sort.SliceStable(output.Transitions, func(i, j int) bool {
+		return output.Transitions[i].Count > output.Transitions[j].Count
+	})
+	return output
 }
 
 func (s *Server) handleGetCategory(w http.ResponseWriter, r *http.Request) {
@@ -151,3 +493,221 @@ func (s *Server) handleGetCategory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(category)
 }
+
+// handleGetSuggestions mines the stored classifications, corrected by their
+// feedback, for candidate keywords and phrases.
+func (s *Server) handleGetSuggestions(w http.ResponseWriter, r *http.Request) {
+	var opts analysis.Options
+	for param, value := range map[string]*int{"limit": &opts.Limit, "minCount": &opts.MinCount} {
+		if raw := r.URL.Query().Get(param); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid "+param, http.StatusBadRequest)
+				return
+			}
+			*value = n
+		}
+	}
+
+	classifications, err := s.db.GetClassifications()
+	if err != nil {
+		http.Error(w, "Failed to get classifications", http.StatusInternalServerError)
+		return
+	}
+	feedback, err := s.db.GetFeedback()
+	if err != nil {
+		http.Error(w, "Failed to get feedback", http.StatusInternalServerError)
+		return
+	}
+	categories, err := s.db.GetCategories()
+	if err != nil {
+		http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+		return
+	}
+	lexicons, err := s.db.GetLexicons()
+	if err != nil {
+		http.Error(w, "Failed to get lexicons", http.StatusInternalServerError)
+		return
+	}
+	opts.Existing, err = proc.ExpandLexicons(categories, lexicons)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := analysis.Suggest(analysis.Documents(classifications, feedback), opts)
+	if category := r.URL.Query().Get("category"); category != "" {
+		filtered := make([]analysis.CategorySuggestions, 0, 1)
+		for _, suggestions := range report.Categories {
+			if suggestions.Category == category {
+				filtered = append(filtered, suggestions)
+			}
+		}
+		report.Categories = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) handleGetExamples(w http.ResponseWriter, r *http.Request) {
+	examples, err := s.db.GetExamples()
+	if err != nil {
+		http.Error(w, "Failed to get examples", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proc.ExampleOutputData{Examples: examples})
+}
+
+func (s *Server) handleCreateExamples(w http.ResponseWriter, r *http.Request) {
+	var examples []proc.Example
+	if err := json.NewDecoder(r.Body).Decode(&examples); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	for _, example := range examples {
+		if err := example.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, example := range examples {
+		if err := s.db.AddExample(example); err != nil {
+			http.Error(w, "Failed to create example", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := s.reloadClassifier(); err != nil {
+		http.Error(w, "Failed to reload classifier", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(proc.ExampleOutputData{Examples: examples})
+}
+
+func (s *Server) handleGetLexicons(w http.ResponseWriter, r *http.Request) {
+	lexicons, err := s.db.GetLexicons()
+	if err != nil {
+		http.Error(w, "Failed to get lexicons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proc.LexiconOutputData{Lexicons: lexicons})
+}
+
+func (s *Server) handleGetLexicon(w http.ResponseWriter, r *http.Request) {
+	lexicon, err := s.db.GetLexicon(r.PathValue("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Lexicon not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get lexicon", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lexicon)
+}
+
+// handleCreateLexicons adds lexicons or replaces those of the same name. Every
+// category that references one is recompiled with its new terms.
+func (s *Server) handleCreateLexicons(w http.ResponseWriter, r *http.Request) {
+	var lexicons []proc.Lexicon
+	if err := json.NewDecoder(r.Body).Decode(&lexicons); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	for _, lexicon := range lexicons {
+		if err := lexicon.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, lexicon := range lexicons {
+		if err := s.db.AddLexicon(lexicon); err != nil {
+			http.Error(w, "Failed to create lexicon", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := s.reloadClassifier(); err != nil {
+		http.Error(w, "Failed to reload classifier", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(proc.LexiconOutputData{Lexicons: lexicons})
+}
+
+// handleDeleteLexicon refuses to delete a lexicon that categories still
+// reference.
+func (s *Server) handleDeleteLexicon(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	categories, err := s.db.GetCategories()
+	if err != nil {
+		http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+		return
+	}
+	if users := proc.LexiconUsers(categories, name); len(users) > 0 {
+		http.Error(w, fmt.Sprintf("Lexicon is used by %s", strings.Join(users, ", ")), http.StatusConflict)
+		return
+	}
+
+	if err := s.db.DeleteLexicon(name); err != nil {
+		http.Error(w, "Failed to delete lexicon", http.StatusInternalServerError)
+		return
+	}
+	if err := s.reloadClassifier(); err != nil {
+		http.Error(w, "Failed to reload classifier", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lintCategories lints the new categories against the merged set, returning
+// errors separately as messages.
+func lintCategories(merged, categories []proc.Category) ([]proc.Finding, []string) {
+	names := make([]string, 0, len(categories))
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+
+	findings := make([]proc.Finding, 0)
+	errs := make([]string, 0)
+	for _, finding := range proc.LintChanges(merged, names) {
+		if finding.Severity == proc.SeverityError {
+			errs = append(errs, finding.String())
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	return findings, errs
+}
+
+// mergeCategories returns the existing categories with the new ones added or
+// replacing those of the same name.
+func mergeCategories(existing, categories []proc.Category) []proc.Category {
+	merged := make([]proc.Category, 0, len(existing)+len(categories))
+	replaced := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		replaced[category.Name] = true
+	}
+	for _, category := range existing {
+		if !replaced[category.Name] {
+			merged = append(merged, category)
+		}
+	}
+	return append(merged, categories...)
+}
@@ -6,8 +6,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
+	"sync"
 	"testing"
 
+	"cfs/analysis"
 	"cfs/proc"
 )
 
@@ -31,8 +34,8 @@ func TestEndpoints(t *testing.T) {
 	// Create Categories
 	t.Run("POST /cfs/c", func(t *testing.T) {
 		categories := []proc.Category{
-			{Name: "TestCategory1", Keywords: []string{"test1", "testing1"}},
-			{Name: "TestCategory2", Keywords: []string{"test2", "testing2"}},
+			{Name: "TestCategory1", Keywords: proc.Terms("test1", "testing1")},
+			{Name: "TestCategory2", Keywords: proc.Terms("test2", "testing2")},
 		}
 		body, _ := json.Marshal(categories)
 		req := httptest.NewRequest("POST", "/cfs/c", bytes.NewBuffer(body))
@@ -44,6 +47,33 @@ func TestEndpoints(t *testing.T) {
 		}
 	})
 
+	// Created categories are used without a restart
+	t.Run("Reload classifier", func(t *testing.T) {
+		result := s.rules.Load().Classify("testing1 reload")
+		if result.Category != "TestCategory1" {
+			t.Errorf("Expected category %s, got %s", "TestCategory1", result.Category)
+		}
+	})
+
+	// Reloads do not race with in-flight classifications
+	t.Run("Concurrent reload", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if err := s.reloadClassifier(); err != nil {
+					t.Errorf("Failed to reload classifier: %v", err)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				s.rules.Load().Classify("testing2 concurrent")
+			}()
+		}
+		wg.Wait()
+	})
+
 	// Get Categories
 	t.Run("GET /cfs/c", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/cfs/c", nil)
@@ -78,6 +108,30 @@ func TestEndpoints(t *testing.T) {
 		}
 	})
 
+	// Create Classifications with multiple labels
+	t.Run("POST /cfs/i multi-label", func(t *testing.T) {
+		input := proc.InputData{
+			Items: []string{"test testing1 testing2 test2"},
+			TopN:  2,
+		}
+		body, _ := json.Marshal(input)
+		req := httptest.NewRequest("POST", "/cfs/i", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		s.handleCreateClassifications(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		var response proc.ClassificationOutputData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Results) != 1 || len(response.Results[0].Labels) != 2 {
+			t.Errorf("Expected 1 result with 2 labels, got %+v", response.Results)
+		}
+	})
+
 	// Get Classifications
 	t.Run("GET /cfs/i", func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/cfs/i", nil)
@@ -108,6 +162,190 @@ func TestEndpoints(t *testing.T) {
 		}
 	})
 
+	// Category weights round-trip through the database
+	t.Run("GET /cfs/c/{category} weights", func(t *testing.T) {
+		body := `[{"name":"TestWeighted","keywords":["testweight",{"term":"testheavy","weight":2}],"weights":{"keyword":0.25}}]`
+		req := httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		req = httptest.NewRequest("GET", "/cfs/c?category=TestWeighted", nil)
+		w = httptest.NewRecorder()
+		s.handleGetCategory(w, req)
+
+		var category proc.Category
+		if err := json.NewDecoder(w.Body).Decode(&category); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if category.Weights == nil || category.Weights.Keyword == nil || *category.Weights.Keyword != 0.25 {
+			t.Errorf("Expected keyword weight 0.25, got %+v", category.Weights)
+		}
+		if len(category.Keywords) != 2 || category.Keywords[1].Weight == nil || *category.Keywords[1].Weight != 2 {
+			t.Errorf("Expected term weight 2, got %+v", category.Keywords)
+		}
+	})
+
+	// Patterns are stored and reported with the matched text
+	t.Run("POST /cfs/i patterns", func(t *testing.T) {
+		body := `[{"name":"TestPatterned","patterns":["TICKET-\\d+"]}]`
+		req := httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		body = `{"items":["test about TICKET-42"]}`
+		req = httptest.NewRequest("POST", "/cfs/i", bytes.NewBufferString(body))
+		w = httptest.NewRecorder()
+		s.handleCreateClassifications(w, req)
+
+		var response proc.ClassificationOutputData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		result := response.Results[0]
+		if result.Category != "TestPatterned" || len(result.Matches) != 1 || result.Matches[0].Text != "TICKET-42" {
+			t.Errorf("Expected TestPatterned matching TICKET-42, got %+v", result)
+		}
+	})
+
+	// Categories nest under their parents
+	t.Run("GET /cfs/c tree", func(t *testing.T) {
+		categories := []proc.Category{
+			{Name: "TestParent", Keywords: proc.Terms("testparent")},
+			{Name: "TestChild", Parent: "TestParent", Keywords: proc.Terms("testchild")},
+		}
+		body, _ := json.Marshal(categories)
+		req := httptest.NewRequest("POST", "/cfs/c", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		req = httptest.NewRequest("GET", "/cfs/c?tree=true", nil)
+		w = httptest.NewRecorder()
+		s.handleGetCategories(w, req)
+
+		var response proc.CategoryTreeOutputData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		found := false
+		for _, node := range response.Categories {
+			if node.Name == "TestParent" {
+				found = len(node.Children) == 1 && node.Children[0].Name == "TestChild"
+			}
+		}
+		if !found {
+			t.Errorf("Expected TestChild under TestParent, got %+v", response.Categories)
+		}
+
+		result := s.rules.Load().Classify("testchild testparent")
+		if result.Category != "TestChild" || strings.Join(result.Path, " > ") != "TestParent > TestChild" {
+			t.Errorf("Expected TestParent > TestChild, got %s %v", result.Category, result.Path)
+		}
+	})
+
+	// Concurrent parent changes cannot store a cycle between them
+	t.Run("POST /cfs/c concurrent cycle", func(t *testing.T) {
+		body := `[{"name":"TestRaceA","keywords":["testracea"]},{"name":"TestRaceB","keywords":["testraceb"]}]`
+		w := httptest.NewRecorder()
+		s.handleCreateCategories(w, httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		updates := []string{
+			`[{"name":"TestRaceA","parent":"TestRaceB","keywords":["testracea"]}]`,
+			`[{"name":"TestRaceB","parent":"TestRaceA","keywords":["testraceb"]}]`,
+		}
+		codes := make([]int, len(updates))
+		var wg sync.WaitGroup
+		for i, update := range updates {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				s.handleCreateCategories(w, httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(update)))
+				codes[i] = w.Code
+			}()
+		}
+		wg.Wait()
+		if codes[0] == codes[1] {
+			t.Errorf("Expected one update to be rejected, got %v", codes)
+		}
+	})
+
+	// Lint warnings are returned with the created categories
+	t.Run("POST /cfs/c lint warnings", func(t *testing.T) {
+		categories := []proc.Category{
+			{Name: "TestKitchen", Keywords: proc.Terms("testkitchen"), Excluders: proc.Terms("computer")},
+		}
+		body, _ := json.Marshal(categories)
+		req := httptest.NewRequest("POST", "/cfs/c", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		var response proc.CategoryOutputData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Findings) != 1 || response.Findings[0].Other != "Technology" {
+			t.Errorf("Expected one finding against Technology, got %+v", response.Findings)
+		}
+	})
+
+	// Examples train the Naive Bayes and ensemble backends
+	t.Run("POST /cfs/e bayes backend", func(t *testing.T) {
+		examples := []proc.Example{
+			{Text: "quarterly earnings beat analyst forecasts", Category: "TestFinance"},
+			{Text: "the central bank raised interest rates", Category: "TestFinance"},
+			{Text: "the striker scored twice in the final", Category: "TestSport"},
+			{Text: "the coach praised the defence after the match", Category: "TestSport"},
+		}
+		body, _ := json.Marshal(examples)
+		req := httptest.NewRequest("POST", "/cfs/e", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		s.handleCreateExamples(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+
+		body, _ = json.Marshal(proc.InputData{Items: []string{"test: interest rates and earnings"}, Backend: BackendBayes})
+		req = httptest.NewRequest("POST", "/cfs/i", bytes.NewBuffer(body))
+		w = httptest.NewRecorder()
+		s.handleCreateClassifications(w, req)
+
+		var response proc.ClassificationOutputData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result := response.Results[0]; result.Category != "TestFinance" {
+			t.Errorf("Expected category TestFinance, got %s", result.Category)
+		}
+
+		body, _ = json.Marshal(proc.InputData{Items: []string{"test: the striker and the coach"}, Backend: BackendEnsemble})
+		req = httptest.NewRequest("POST", "/cfs/i", bytes.NewBuffer(body))
+		w = httptest.NewRecorder()
+		s.handleCreateClassifications(w, req)
+
+		response = proc.ClassificationOutputData{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if result := response.Results[0]; result.Category != "TestSport" || len(result.Votes) != 2 {
+			t.Errorf("Expected category TestSport with 2 votes, got %s %+v", result.Category, result.Votes)
+		}
+	})
+
 	// Get Single Classification
 	t.Run("GET /cfs/i/{item}", func(t *testing.T) {
 		itemQuery := url.QueryEscape("test item 1")
@@ -120,6 +358,183 @@ func TestEndpoints(t *testing.T) {
 		}
 	})
 
+	// Create Feedback
+	t.Run("POST /cfs/i/{item}/feedback", func(t *testing.T) {
+		before, err := s.db.GetClassification("test item 1")
+		if err != nil {
+			t.Fatalf("Failed to get classification: %v", err)
+		}
+
+		req := httptest.NewRequest("POST", "/cfs/i/test%20item%201/feedback", bytes.NewBufferString(`{"category":"Food and Cooking","reviewer":"alice"}`))
+		req.SetPathValue("item", "test item 1")
+		w := httptest.NewRecorder()
+		s.handleCreateFeedback(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		req = httptest.NewRequest("GET", "/cfs/i/test%20item%201/feedback", nil)
+		req.SetPathValue("item", "test item 1")
+		w = httptest.NewRecorder()
+		s.handleGetFeedback(w, req)
+
+		var response proc.FeedbackOutputData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Feedback) != 1 {
+			t.Fatalf("Expected 1 feedback, got %+v", response.Feedback)
+		}
+		feedback := response.Feedback[0]
+		if feedback.Category != "Food and Cooking" || feedback.Reviewer != "alice" || feedback.Predicted != before.Category || feedback.CreatedAt.IsZero() {
+			t.Errorf("Unexpected feedback %+v", feedback)
+		}
+
+		after, err := s.db.GetClassification("test item 1")
+		if err != nil || after.Category != before.Category {
+			t.Errorf("Expected the stored result to stay %s, got %s (%v)", before.Category, after.Category, err)
+		}
+	})
+
+	// Suggestions are mined from classifications and feedback
+	t.Run("GET /cfs/suggestions", func(t *testing.T) {
+		body, _ := json.Marshal(proc.InputData{Items: []string{
+			"test zeppelin hangar inspection",
+			"test zeppelin hangar repairs",
+			"test zeppelin hangar lease",
+		}})
+		req := httptest.NewRequest("POST", "/cfs/i", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		s.handleCreateClassifications(w, req)
+
+		req = httptest.NewRequest("GET", "/cfs/suggestions?limit=5", nil)
+		w = httptest.NewRecorder()
+		s.handleGetSuggestions(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var report analysis.Report
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		found := false
+		for _, suggestion := range report.Unknown.Phrases {
+			found = found || suggestion.Term == "zeppelin hangar"
+		}
+		if !found || len(report.Unknown.Keywords) > 5 {
+			t.Errorf("Expected zeppelin hangar among at most 5 suggestions, got %+v", report.Unknown)
+		}
+	})
+
+	// Dry-run a draft category without saving it
+	t.Run("POST /cfs/c/dry-run", func(t *testing.T) {
+		draft := proc.Category{Name: "TestDraft", Keywords: proc.Terms("item")}
+		body, _ := json.Marshal(draft)
+		req := httptest.NewRequest("POST", "/cfs/c/dry-run?examples=10", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		s.handleDryRunCategory(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var response proc.DryRunOutputData
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		moved := map[string]bool{}
+		for _, transition := range response.Transitions {
+			if transition.To != "TestDraft" {
+				continue
+			}
+			for _, item := range transition.Examples {
+				moved[item] = true
+			}
+		}
+		if !moved["test item 1"] || !moved["test item 2"] || response.Changed < 2 {
+			t.Errorf("Expected the test items to move to TestDraft, got %+v", response)
+		}
+
+		if result := s.rules.Load().Classify("test item 1"); result.Category == "TestDraft" {
+			t.Errorf("Expected the dry run not to change the classifier")
+		}
+		if _, err := s.db.GetCategory("TestDraft"); err == nil {
+			t.Errorf("Expected the dry run not to save TestDraft")
+		}
+	})
+
+	// Lexicons expand into the categories that reference them
+	t.Run("POST /cfs/lexicons", func(t *testing.T) {
+		body := `[{"name":"test_vendors","terms":["testaws","testazure"]}]`
+		req := httptest.NewRequest("POST", "/cfs/lexicons", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		s.handleCreateLexicons(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+
+		req = httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(`[{"name":"TestVendors","keywords":["@test_vendors"]}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		if result := s.rules.Load().Classify("testazure"); result.Category != "TestVendors" {
+			t.Errorf("Expected TestVendors, got %s", result.Category)
+		}
+
+		req = httptest.NewRequest("POST", "/cfs/lexicons", bytes.NewBufferString(`[{"name":"test_vendors","terms":["testgcp"]}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateLexicons(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+		if result := s.rules.Load().Classify("testgcp"); result.Category != "TestVendors" {
+			t.Errorf("Expected the updated lexicon to classify as TestVendors, got %s", result.Category)
+		}
+
+		req = httptest.NewRequest("GET", "/cfs/lexicons/test_vendors", nil)
+		req.SetPathValue("name", "test_vendors")
+		w = httptest.NewRecorder()
+		s.handleGetLexicon(w, req)
+		var lexicon proc.Lexicon
+		if err := json.NewDecoder(w.Body).Decode(&lexicon); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(lexicon.Terms) != 1 || lexicon.Terms[0] != "testgcp" {
+			t.Errorf("Expected the updated terms, got %v", lexicon.Terms)
+		}
+
+		req = httptest.NewRequest("DELETE", "/cfs/lexicons/test_vendors", nil)
+		req.SetPathValue("name", "test_vendors")
+		w = httptest.NewRecorder()
+		s.handleDeleteLexicon(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d for a lexicon in use, got %d", http.StatusConflict, w.Code)
+		}
+	})
+
+	// The abstain threshold applies whichever backend classifies
+	t.Run("Abstain threshold", func(t *testing.T) {
+		s.AbstainThreshold = 0.99
+		defer func() { s.AbstainThreshold = 0 }()
+
+		for _, backend := range []string{BackendRules, BackendEnsemble} {
+			body, _ := json.Marshal(proc.InputData{Items: []string{"test computer software"}, Backend: backend})
+			req := httptest.NewRequest("POST", "/cfs/i", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			s.handleCreateClassifications(w, req)
+
+			var response proc.ClassificationOutputData
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if result := response.Results[0]; result.Category != "Unknown" || !result.Abstained {
+				t.Errorf("Expected Unknown abstained from %s, got %s %v", backend, result.Category, result.Abstained)
+			}
+		}
+	})
+
 	// Error Cases
 	t.Run("Error Cases", func(t *testing.T) {
 		// Missing category
@@ -145,5 +560,120 @@ func TestEndpoints(t *testing.T) {
 		if w.Code != http.StatusBadRequest {
 			t.Errorf("Expected status %d for invalid JSON, got %d", http.StatusBadRequest, w.Code)
 		}
+
+		// Unknown language in create classification
+		req = httptest.NewRequest("POST", "/cfs/i", bytes.NewBufferString(`{"items":["test"],"language":"xx"}`))
+		w = httptest.NewRecorder()
+		s.handleCreateClassifications(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for unknown language, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Invalid pattern in create category
+		req = httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(`[{"name":"TestBad","patterns":["CVE-(\\d+"]}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for invalid pattern, got %d", http.StatusBadRequest, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "invalid pattern") {
+			t.Errorf("Expected invalid pattern error, got %q", w.Body.String())
+		}
+
+		// Unknown language in create category
+		req = httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(`[{"name":"TestBad","languages":["xx"]}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for unknown category language, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Abstain threshold out of range
+		if err := (&Server{AbstainThreshold: 2}).Init(); err == nil {
+			t.Errorf("Expected an error for abstain threshold 2")
+		}
+
+		// Unknown backend in create classification
+		req = httptest.NewRequest("POST", "/cfs/i", bytes.NewBufferString(`{"items":["test"],"backend":"oracle"}`))
+		w = httptest.NewRecorder()
+		s.handleCreateClassifications(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for unknown backend, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Feedback for an unknown item
+		req = httptest.NewRequest("POST", "/cfs/i/missing/feedback", bytes.NewBufferString(`{"category":"TestCorrected","reviewer":"alice"}`))
+		req.SetPathValue("item", "test missing item")
+		w = httptest.NewRecorder()
+		s.handleCreateFeedback(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status %d for unknown item, got %d", http.StatusNotFound, w.Code)
+		}
+
+		// Feedback naming a category that does not exist
+		req = httptest.NewRequest("POST", "/cfs/i/test/feedback", bytes.NewBufferString(`{"category":"Tecnology","reviewer":"alice"}`))
+		req.SetPathValue("item", "test item 1")
+		w = httptest.NewRecorder()
+		s.handleCreateFeedback(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for unknown category, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Feedback without a reviewer
+		req = httptest.NewRequest("POST", "/cfs/i/test/feedback", bytes.NewBufferString(`{"category":"TestCorrected"}`))
+		req.SetPathValue("item", "test item 1")
+		w = httptest.NewRecorder()
+		s.handleCreateFeedback(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for missing reviewer, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Invalid suggestion limit
+		req = httptest.NewRequest("GET", "/cfs/suggestions?limit=many", nil)
+		w = httptest.NewRecorder()
+		s.handleGetSuggestions(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for invalid limit, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Parent cycle in create category
+		req = httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(`[{"name":"TestCycleA","parent":"TestCycleB"},{"name":"TestCycleB","parent":"TestCycleA"}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for parent cycle, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Invalid example count in dry run
+		req = httptest.NewRequest("POST", "/cfs/c/dry-run?examples=-1", bytes.NewBufferString(`{"name":"TestDraft"}`))
+		w = httptest.NewRecorder()
+		s.handleDryRunCategory(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for invalid examples, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Unknown lexicon in create category
+		req = httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(`[{"name":"TestBad","keywords":["@missing"]}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for unknown lexicon, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Invalid lexicon name
+		req = httptest.NewRequest("POST", "/cfs/lexicons", bytes.NewBufferString(`[{"name":"test vendors","terms":["aws"]}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateLexicons(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for invalid lexicon name, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		// Keyword that is also an excluder in create category
+		req = httptest.NewRequest("POST", "/cfs/c", bytes.NewBufferString(`[{"name":"TestLint","keywords":["testlint"],"excluders":["testlint"]}]`))
+		w = httptest.NewRecorder()
+		s.handleCreateCategories(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for self-excluding keyword, got %d", http.StatusBadRequest, w.Code)
+		}
 	})
 }